@@ -0,0 +1,100 @@
+package memoryfs
+
+import (
+	"io/fs"
+)
+
+// AccessChecker decides whether an operation may be performed on a file or directory. A non-nil error denies
+// the operation.
+type AccessChecker func(op string, info fs.FileInfo) error
+
+// SetAccessChecker installs fn to be consulted before every operation which reads the content of a named file
+// ("open"), such as Open, ReadAt or ContentType, lists a named directory ("readdir"), such as ReadDir or
+// ReadDirDepth, or writes a file ("write"), such as WriteFile, WriteLazyFile, AppendWriter or Symlink, replacing
+// any previous checker. ToMap leaves out the files which fn denies, and Glob and GlobStar the matches within
+// directories which fn denies listing. For writes which create a new file, fn
+// receives the info of the directory the file will be created in. If fn returns an error, the operation fails with an error which wraps
+// it and which also satisfies errors.Is(err, fs.ErrPermission). Passing nil removes the checker.
+func (m *FS) SetAccessChecker(fn AccessChecker) {
+	m.dir.tree.checkerMu.Lock()
+	defer m.dir.tree.checkerMu.Unlock()
+	m.dir.tree.checker = fn
+}
+
+// checkAccess consults the access checker, if any, for an operation on the resolved path
+func (m *FS) checkAccess(op string, path string) error {
+	m.dir.tree.checkerMu.RLock()
+	checker := m.dir.tree.checker
+	m.dir.tree.checkerMu.RUnlock()
+	if checker == nil {
+		return nil
+	}
+
+	info, err := m.stat(op, path)
+	if err != nil {
+		if op != "write" {
+			// let the operation itself report the missing file
+			return nil
+		}
+		parent, _ := splitPath(path, m.dir.sep())
+		if info, err = m.stat(op, parent); err != nil {
+			return nil
+		}
+	}
+
+	if err := checker(op, info); err != nil {
+		return &fs.PathError{Op: op, Path: path, Err: &accessError{err: err}}
+	}
+	return nil
+}
+
+// listable returns those of the resolved paths whose parent directories may all be listed ("readdir"), so that
+// matching a pattern does not reveal the names within a directory hidden by the access checker
+func (m *FS) listable(paths []string) []string {
+	m.dir.tree.checkerMu.RLock()
+	checker := m.dir.tree.checker
+	m.dir.tree.checkerMu.RUnlock()
+	if checker == nil {
+		return paths
+	}
+
+	sep := m.dir.sep()
+	allowed := map[string]bool{}
+	var check func(dir string) bool
+	check = func(dir string) bool {
+		if ok, seen := allowed[dir]; seen {
+			return ok
+		}
+		ok := m.checkAccess("readdir", dir) == nil
+		if ok && dir != "" {
+			parent, _ := splitPath(dir, sep)
+			ok = check(parent)
+		}
+		allowed[dir] = ok
+		return ok
+	}
+	kept := paths[:0]
+	for _, path := range paths {
+		if parent, _ := splitPath(path, sep); check(parent) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// accessError wraps an error returned by an AccessChecker so that it is also recognised as fs.ErrPermission
+type accessError struct {
+	err error
+}
+
+func (e *accessError) Error() string {
+	return e.err.Error()
+}
+
+func (e *accessError) Unwrap() error {
+	return e.err
+}
+
+func (e *accessError) Is(target error) bool {
+	return target == fs.ErrPermission
+}
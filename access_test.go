@@ -0,0 +1,141 @@
+package memoryfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AccessChecker(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("public", 0o755))
+	require.NoError(t, memfs.MkdirAll("private", 0o700))
+	require.NoError(t, memfs.WriteFile("public/readme.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.WriteFile("private/key.pem", []byte("secret"), 0o600))
+
+	errDenied := errors.New("denied by policy")
+	var checked []string
+	memfs.SetAccessChecker(func(op string, info fs.FileInfo) error {
+		checked = append(checked, op+":"+info.Name())
+		if info.Mode().Perm()&0o004 == 0 {
+			return errDenied
+		}
+		return nil
+	})
+
+	data, err := memfs.ReadFile("public/readme.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = memfs.Open("private/key.pem")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errDenied)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	_, err = memfs.ReadDir("private")
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	err = memfs.WriteFile("private/new.txt", []byte("new"), 0o644)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	_, err = memfs.Stat("private/new.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, memfs.WriteFile("public/new.txt", []byte("new"), 0o644))
+
+	assert.Equal(t, []string{
+		"open:readme.txt",
+		"open:key.pem",
+		"readdir:private",
+		"write:private",
+		"write:public",
+	}, checked)
+
+	memfs.SetAccessChecker(nil)
+	_, err = memfs.ReadFile("private/key.pem")
+	require.NoError(t, err)
+}
+
+func Test_AccessCheckerDeniesEveryOperation(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o755))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("dir/file.txt", "/", separator), []byte("content"), 0o644))
+	file := strings.ReplaceAll("dir/file.txt", "/", separator)
+	created := strings.ReplaceAll("dir/new.txt", "/", separator)
+
+	errDenied := errors.New("denied by policy")
+	memfs.SetAccessChecker(func(op string, info fs.FileInfo) error {
+		return errDenied
+	})
+
+	lazy := func() (io.Reader, error) {
+		return strings.NewReader("lazy"), nil
+	}
+	discard := func(_ interface{}, err error) error {
+		return err
+	}
+	for name, op := range map[string]func() error{
+		"Open":          func() error { return discard(memfs.Open(file)) },
+		"OpenContext":   func() error { return discard(memfs.OpenContext(context.Background(), file)) },
+		"OpenNoFollow":  func() error { return discard(memfs.OpenNoFollow(file)) },
+		"OpenConcat":    func() error { return discard(memfs.OpenConcat(file)) },
+		"ReadFile":      func() error { return discard(memfs.ReadFile(file)) },
+		"ReadFileInfo":  func() error { _, _, err := memfs.ReadFileInfo(file); return err },
+		"Bytes":         func() error { return discard(memfs.Bytes(file)) },
+		"ReadAt":        func() error { return discard(memfs.ReadAt(file, 0, 1)) },
+		"ReadHead":      func() error { return discard(memfs.ReadHead(file, 1)) },
+		"ContentType":   func() error { return discard(memfs.ContentType(file)) },
+		"IsBinary":      func() error { return discard(memfs.IsBinary(file)) },
+		"DecodeFile":    func() error { return memfs.DecodeFile(file, nil, func(io.Reader, interface{}) error { return nil }) },
+		"Reflink":       func() error { return memfs.Reflink(file, created) },
+		"ReadDir":       func() error { return discard(memfs.ReadDir("dir")) },
+		"ReadDirFilter": func() error { return discard(memfs.ReadDirFilter("dir", nil)) },
+		"ReadDirDirs":   func() error { return discard(memfs.ReadDirDirs("dir")) },
+		"ReadDirFiles":  func() error { return discard(memfs.ReadDirFiles("dir")) },
+		"ReadDirPaths":  func() error { return discard(memfs.ReadDirPaths("dir")) },
+		"ReadDirDepth":  func() error { return discard(memfs.ReadDirDepth("dir", -1)) },
+		"Newest":        func() error { _, _, err := memfs.Newest("dir"); return err },
+		"Rename":        func() error { return memfs.Rename(file, created) },
+		"WriteFile":     func() error { return memfs.WriteFile(created, nil, 0o644) },
+		"WriteFileAt":   func() error { return memfs.WriteFileAt(created, nil, 0o644, time.Now()) },
+		"WriteFileDeep": func() error {
+			return memfs.WriteFileDeep(strings.ReplaceAll("dir/a/b.txt", "/", separator), nil, 0o644)
+		},
+		"WriteReader":   func() error { return memfs.WriteReader(created, strings.NewReader("new"), 0o644) },
+		"WriteLazyFile": func() error { return memfs.WriteLazyFile(created, lazy, 0o644) },
+		"AppendWriter":  func() error { return discard(memfs.AppendWriter(file)) },
+		"Symlink":       func() error { return memfs.Symlink("file.txt", created) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := op()
+			assert.ErrorIs(t, err, errDenied)
+			assert.ErrorIs(t, err, fs.ErrPermission)
+		})
+	}
+
+	assert.Empty(t, memfs.ToMap())
+	matches, err := memfs.Glob("dir/*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+	matches, err = memfs.GlobStar("**")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+	memfs.SetAccessChecker(nil)
+	_, err = memfs.Stat(strings.ReplaceAll("dir/a", "/", separator))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	matches, err = memfs.Glob("dir/*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, matches)
+	paths, err := memfs.ReadDirPaths("dir")
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, paths)
+	data, err := memfs.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+}
@@ -0,0 +1,53 @@
+package memoryfs
+
+import "sync"
+
+// AccessEvent records a single access to the filesystem, see WithAccessLog
+type AccessEvent struct {
+	Op   string // "open" or "readdir"
+	Path string // the path accessed, after cleansing and resolving symbolic links
+}
+
+// accessLog is the ordered record of accesses kept by a tree
+type accessLog struct {
+	sync.Mutex
+	events []AccessEvent
+}
+
+// WithAccessLog records every Open and ReadDir call made on the filesystem, in order, so that tests can assert the
+// sequence in which files are visited. The record is returned by AccessLog. Calls which fail are also recorded.
+func WithAccessLog() Option {
+	return func(o *options) {
+		o.accessLog = true
+	}
+}
+
+// AccessLog returns a copy of the accesses recorded since the filesystem was created or ResetAccessLog was last
+// called. It is always empty unless the filesystem was created with WithAccessLog.
+func (m *FS) AccessLog() []AccessEvent {
+	log := &m.dir.tree.accesses
+	log.Lock()
+	defer log.Unlock()
+	events := make([]AccessEvent, len(log.events))
+	copy(events, log.events)
+	return events
+}
+
+// ResetAccessLog discards the accesses recorded so far.
+func (m *FS) ResetAccessLog() {
+	log := &m.dir.tree.accesses
+	log.Lock()
+	defer log.Unlock()
+	log.events = nil
+}
+
+// recordAccess appends an access to the log, if enabled
+func (m *FS) recordAccess(op string, path string) {
+	if !m.dir.tree.opts.accessLog {
+		return
+	}
+	log := &m.dir.tree.accesses
+	log.Lock()
+	defer log.Unlock()
+	log.events = append(log.events, AccessEvent{Op: op, Path: path})
+}
@@ -0,0 +1,51 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AccessLog(t *testing.T) {
+	memfs := New(WithAccessLog())
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/one.txt", []byte("1"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/b/two.txt", []byte("2"), 0o644))
+	assert.Empty(t, memfs.AccessLog())
+
+	require.NoError(t, fs.WalkDir(memfs, "a", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		_, err = memfs.ReadFile(path)
+		return err
+	}))
+	_, err := memfs.Open("a/missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	path := func(p string) string {
+		return strings.ReplaceAll(p, "/", separator)
+	}
+	expected := []AccessEvent{
+		{Op: "readdir", Path: path("a")},
+		{Op: "readdir", Path: path("a/b")},
+		{Op: "open", Path: path("a/b/two.txt")},
+		{Op: "open", Path: path("a/one.txt")},
+		{Op: "open", Path: path("a/missing.txt")},
+	}
+	assert.Equal(t, expected, memfs.AccessLog())
+
+	memfs.ResetAccessLog()
+	assert.Empty(t, memfs.AccessLog())
+}
+
+func Test_AccessLogDisabled(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", nil, 0o644))
+	_, err := memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Empty(t, memfs.AccessLog())
+}
@@ -0,0 +1,101 @@
+package memoryfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"time"
+)
+
+const defaultFilePerm = 0o644
+
+type appendWriter struct {
+	fs     *FS
+	path   string
+	buffer bytes.Buffer
+	closed bool
+}
+
+// AppendWriter returns a writer whose content is appended to the named file when it is closed. The file is created
+// with mode 0o644 if it does not exist. An error is returned if the path is a directory.
+func (m *FS) AppendWriter(name string) (io.WriteCloser, error) {
+	path, err := m.realpath("append", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkNameLen("append", path); err != nil {
+		return nil, err
+	}
+	if err := m.checkDepth("append", path); err != nil {
+		return nil, err
+	}
+	if _, err := m.dir.getDir(path); err == nil {
+		return nil, &fs.PathError{Op: "append", Path: path, Err: ErrIsDir}
+	}
+	if err := m.checkAccess("write", path); err != nil {
+		return nil, err
+	}
+	if _, err := m.dir.getFile(path); err != nil {
+		if err := m.dir.WriteFile(path, nil, defaultFilePerm); err != nil {
+			return nil, &fs.PathError{Op: "append", Path: path, Err: err}
+		}
+		m.recordEvent(Event{Op: "write", Path: path, Mode: defaultFilePerm, ModTime: time.Now()})
+	}
+	return &appendWriter{
+		fs:   m,
+		path: path,
+	}, nil
+}
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, fs.ErrClosed
+	}
+	return a.buffer.Write(p)
+}
+
+// Close commits the written data to the end of the file
+func (a *appendWriter) Close() error {
+	if a.closed {
+		return fs.ErrClosed
+	}
+	a.closed = true
+	return a.fs.appendFile(a.path, a.buffer.Bytes())
+}
+
+// appendFile appends data to the existing file at the resolved path. Appending is not considered to clobber the
+// file, so it is permitted by WithNoClobber.
+func (m *FS) appendFile(path string, data []byte) error {
+	parentPath, _ := splitPath(path, m.dir.sep())
+	parent, err := m.dir.getDir(parentPath)
+	if err != nil {
+		return &fs.PathError{Op: "append", Path: path, Err: err}
+	}
+	f, err := m.dir.getFile(path)
+	if err != nil {
+		return &fs.PathError{Op: "append", Path: path, Err: err}
+	}
+	// the lock is held from reading the content to replacing it, so that concurrent appends are not lost
+	f.appendMu.Lock()
+	defer f.appendMu.Unlock()
+	if f.isImmutable() {
+		return &fs.PathError{Op: "append", Path: path, Err: ErrImmutable}
+	}
+	existing, err := f.readAll()
+	if err != nil {
+		return &fs.PathError{Op: "append", Path: path, Err: err}
+	}
+	combined := append(existing, data...)
+	mode := f.stat().Mode()
+	stored, err := m.dir.tree.store(combined, mode)
+	if err != nil {
+		return &fs.PathError{Op: "append", Path: path, Err: err}
+	}
+	modified := time.Now()
+	if err := f.overwrite(combined, stored, mode, modified); err != nil {
+		return &fs.PathError{Op: "append", Path: path, Err: err}
+	}
+	parent.invalidateSize()
+	m.recordEvent(Event{Op: "write", Path: path, Data: combined, Mode: mode, ModTime: modified})
+	return nil
+}
@@ -0,0 +1,86 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AppendWriter(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("log.txt", []byte("line 1\n"), 0o600))
+
+	w, err := memfs.AppendWriter("log.txt")
+	require.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader("line 2\nline 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data, err := memfs.ReadFile("log.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\nline 3\n", string(data))
+
+	info, err := memfs.Stat("log.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size())
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+
+	_, err = w.Write([]byte("too late"))
+	assert.ErrorIs(t, err, fs.ErrClosed)
+
+	w, err = memfs.AppendWriter("new.txt")
+	require.NoError(t, err)
+	_, err = memfs.Stat("new.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("created"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data, err = memfs.ReadFile("new.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "created", string(data))
+
+	_, err = memfs.AppendWriter("dir")
+	assert.ErrorIs(t, err, syscall.EISDIR)
+}
+
+func Test_AppendWriterConcurrent(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("log.txt", nil, 0o644))
+
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := memfs.AppendWriter("log.txt")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := w.Write([]byte("line\n")); err != nil {
+				errs <- err
+				return
+			}
+			errs <- w.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	data, err := memfs.ReadFile("log.txt")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("line\n", writers), string(data))
+}
@@ -0,0 +1,72 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"time"
+)
+
+const importDirPerm = 0o755
+
+// importPath validates and cleanses an entry path from an archive. If the path is unsafe and the FS is configured to
+// skip such entries, ok is false and no error is returned.
+func (m *FS) importPath(op string, name string) (path string, ok bool, err error) {
+	path, err = secureCleanse(name, m.dir.sep())
+	if err != nil {
+		if m.dir.tree.opts.skipUnsafePaths {
+			return "", false, nil
+		}
+		return "", false, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return path, path != "", nil
+}
+
+func (m *FS) importDir(path string, perm fs.FileMode, modified time.Time) error {
+	if err := m.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	return m.SetModified(path, modified)
+}
+
+// importFile writes a regular file from an archive. A symbolic link already at the path is replaced rather than
+// followed, as a later image layer replaces an earlier one.
+func (m *FS) importFile(path string, data []byte, perm fs.FileMode, modified time.Time) error {
+	if f, err := m.dir.getFile(path); err == nil && f.isSymlink() {
+		if err := m.dir.Remove(path); err != nil {
+			return &fs.PathError{Op: "write", Path: path, Err: err}
+		}
+		m.recordEvent(Event{Op: "remove", Path: path})
+	}
+	return m.writeFileDeep(path, data, perm, modified, importDirPerm)
+}
+
+// importLink creates a symbolic link from an archive with the given permissions and modification time. As with
+// regular files, a link replaces any file or link already at the path, as a later image layer replaces an earlier
+// one.
+func (m *FS) importLink(path string, target string, perm fs.FileMode, modified time.Time) error {
+	var created []string
+	if parent, _ := splitPath(path, m.dir.sep()); parent != "" {
+		created = m.missingDirs(parent)
+		if err := m.MkdirAll(parent, m.implicitDirPerm(parent, importDirPerm)); err != nil {
+			return err
+		}
+	}
+	if _, err := m.dir.getFile(path); err == nil {
+		if err := m.dir.Remove(path); err != nil {
+			return &fs.PathError{Op: "symlink", Path: path, Err: err}
+		}
+		m.recordEvent(Event{Op: "remove", Path: path})
+	}
+	if err := m.Symlink(target, path); err != nil {
+		m.removeDirs(created)
+		return err
+	}
+	f, err := m.dir.getFile(path)
+	if err != nil {
+		return &fs.PathError{Op: "symlink", Path: path, Err: err}
+	}
+	f.Lock()
+	f.info.mode = fs.ModeSymlink | perm
+	f.info.modified = modified
+	f.Unlock()
+	return nil
+}
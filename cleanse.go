@@ -1,17 +1,79 @@
 package memoryfs
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
 )
 
-func cleanse(path string) string {
-	path = strings.ReplaceAll(path, "/", separator)
-	path = filepath.Clean(path)
-	path = strings.TrimPrefix(path, "."+separator)
-	path = strings.TrimPrefix(path, separator)
-	if path == "." {
+// Clean returns the path as it is interpreted by the filesystem, relative to its root, before any symbolic links are
+// resolved. The root itself is returned as "". Clean is idempotent.
+func (m *FS) Clean(path string) string {
+	if m.dir.getParent() == nil && path == m.dir.info.name {
 		return ""
 	}
-	return path
+	return cleanse(path, m.dir.sep())
+}
+
+// Rel returns a path which is lexically equivalent to target when joined to base, after both have been cleaned
+// with Clean. An error is returned if target cannot be made relative to base.
+func (m *FS) Rel(base, target string) (string, error) {
+	sep := m.dir.sep()
+	rel, err := filepath.Rel("/"+toSlash(m.Clean(base), sep), "/"+toSlash(m.Clean(target), sep))
+	if err != nil {
+		return "", err
+	}
+	return fromSlash(filepath.ToSlash(rel), sep), nil
+}
+
+// cleanse cleans a path using the separator sep. A forward slash is always accepted as a separator.
+func cleanse(p string, sep string) string {
+	p = path.Clean(toSlash(p, sep))
+	p = strings.TrimPrefix(p, "/")
+	if p == "." {
+		return ""
+	}
+	return fromSlash(p, sep)
+}
+
+// secureCleanse cleanses a path from an untrusted source such as an archive entry.
+// Absolute paths are re-rooted, but paths which would traverse above the root are rejected.
+func secureCleanse(p string, sep string) (string, error) {
+	cleaned := path.Clean(toSlash(p, sep))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrUnsafePath
+	}
+	return cleanse(cleaned, sep), nil
+}
+
+// toSlash replaces each separator sep in path with a forward slash
+func toSlash(path string, sep string) string {
+	if sep == "/" {
+		return path
+	}
+	return strings.ReplaceAll(path, sep, "/")
+}
+
+// fromSlash replaces each forward slash in path with the separator sep
+func fromSlash(path string, sep string) string {
+	if sep == "/" {
+		return path
+	}
+	return strings.ReplaceAll(path, "/", sep)
+}
+
+// splitPath splits a cleansed path into its parent directory and base name
+func splitPath(path string, sep string) (parent string, name string) {
+	if i := strings.LastIndex(path, sep); i >= 0 {
+		return path[:i], path[i+len(sep):]
+	}
+	return "", path
+}
+
+// joinPath joins a cleansed parent path and a base name
+func joinPath(parent string, name string, sep string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + sep + name
 }
@@ -0,0 +1,56 @@
+package memoryfs
+
+import "sync/atomic"
+
+// Compact rebuilds the maps holding the entries of every directory, releasing the capacity Go maps retain after
+// entries are deleted, and recomputes the counts used by WithMaxFiles and WithMaxEntries and the cached directory
+// sizes from scratch. It is a maintenance operation for long-lived filesystems which have seen heavy churn, and
+// covers the whole filesystem, even when called on a view returned by Sub. Directories of mounts which have not
+// been loaded yet are left untouched. Compact is safe for concurrent use, but is intended to be called while the
+// filesystem is idle, as changes made while it runs may be missed by the recomputed counts.
+func (m *FS) Compact() {
+	root := m.dir
+	for root.getParent() != nil {
+		root = root.getParent()
+	}
+	files, entries := root.compact()
+	atomic.StoreInt64(&root.tree.files, files)
+	atomic.StoreInt64(&root.tree.entries, entries)
+}
+
+// compact rebuilds the maps of d and its loaded subdirectories, returning the number of regular files and the
+// number of entries within them
+func (d *dir) compact() (files int64, entries int64) {
+	d.Lock()
+	if d.source != nil {
+		d.Unlock()
+		return 0, 0
+	}
+	dirs := make(map[string]*dir, len(d.dirs))
+	subs := make([]*dir, 0, len(d.dirs))
+	for name, sub := range d.dirs {
+		dirs[name] = sub
+		subs = append(subs, sub)
+	}
+	children := make(map[string]*file, len(d.files))
+	for name, f := range d.files {
+		children[name] = f
+		if f.stat().Mode().IsRegular() {
+			files++
+		}
+	}
+	entries = int64(len(dirs) + len(children))
+	d.dirs, d.files = dirs, children
+	d.Unlock()
+
+	d.sizeMu.Lock()
+	d.sizeValid = 0
+	d.sizeMu.Unlock()
+
+	for _, sub := range subs {
+		subFiles, subEntries := sub.compact()
+		files += subFiles
+		entries += subEntries
+	}
+	return files, entries
+}
@@ -0,0 +1,79 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Compact(t *testing.T) {
+	memfs := New(WithMaxFiles(1000))
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 100; i++ {
+			dir := strings.ReplaceAll(fmt.Sprintf("churn/%d", i%10), "/", separator)
+			require.NoError(t, memfs.MkdirAll(dir, 0o700))
+			name := strings.ReplaceAll(fmt.Sprintf("churn/%d/file%d.txt", i%10, i), "/", separator)
+			require.NoError(t, memfs.WriteFile(name, []byte(strings.Repeat("x", round+1)), 0o644))
+		}
+		_, err := memfs.DirSize(".")
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			if i%4 != round%4 {
+				name := strings.ReplaceAll(fmt.Sprintf("churn/%d/file%d.txt", i%10, i), "/", separator)
+				require.NoError(t, memfs.Remove(name))
+			}
+		}
+	}
+	require.NoError(t, memfs.RemoveAll(strings.ReplaceAll("churn/9", "/", separator)))
+	require.NoError(t, memfs.WriteFile("kept.txt", []byte("kept"), 0o644))
+
+	before, err := memfs.DirSize(".")
+	require.NoError(t, err)
+	// simulate drift in the counters, which Compact should correct
+	atomic.StoreInt64(&memfs.dir.tree.files, 999)
+
+	memfs.Compact()
+	require.NoError(t, memfs.Verify())
+
+	var files int64
+	var bytes int64
+	require.NoError(t, memfs.WalkFollow(".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			require.NoError(t, err)
+			files++
+			bytes += info.Size()
+		}
+		return nil
+	}))
+	assert.Equal(t, files, atomic.LoadInt64(&memfs.dir.tree.files))
+	size, err := memfs.DirSize(".")
+	require.NoError(t, err)
+	assert.Equal(t, bytes, size)
+	assert.Equal(t, before, size)
+
+	data, err := memfs.ReadFile("kept.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "kept", string(data))
+}
+
+func Test_CompactSub(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("a/b", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/b/file.txt", "/", separator), []byte("hello"), 0o644))
+	require.NoError(t, memfs.WriteFile("other.txt", []byte("world"), 0o644))
+	atomic.StoreInt64(&memfs.dir.tree.files, 0)
+
+	sub, err := memfs.Sub("a")
+	require.NoError(t, err)
+	sub.(*FS).Compact()
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&memfs.dir.tree.files))
+	require.NoError(t, memfs.Verify())
+}
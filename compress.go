@@ -0,0 +1,79 @@
+package memoryfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+)
+
+// storage is the content of a file prepared for storing, either in memory, compressed in memory or spilled to disk
+type storage struct {
+	content    []byte
+	spilled    *spillFile
+	compressed bool  // content is gzip-compressed, see WithCompression
+	size       int64 // size of the uncompressed content
+}
+
+// WithCompression stores the content of files gzip-compressed in memory, at the level set with WithGzipLevel,
+// trading the time taken to compress and decompress content for memory. This is transparent to callers: Stat
+// reports the uncompressed size, and content is decompressed whenever it is read. Content spilled to disk with
+// WithSpill and the targets of symbolic links are not compressed.
+func WithCompression() Option {
+	return func(o *options) {
+		o.compression = true
+	}
+}
+
+// store prepares data to be stored as the content of a file with mode perm. The data is copied, so the caller
+// may reuse it.
+func (t *tree) store(data []byte, perm fs.FileMode) (storage, error) {
+	s := storage{size: int64(len(data))}
+	if perm&fs.ModeSymlink != 0 {
+		// symbolic links always keep their target in memory
+		s.content = copyContent(data)
+		return s, nil
+	}
+	spilled, err := t.spill(data)
+	if err != nil {
+		return s, err
+	}
+	if spilled != nil {
+		s.spilled = spilled
+		return s, nil
+	}
+	if !t.opts.compression {
+		s.content = copyContent(data)
+		return s, nil
+	}
+	var buffer bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&buffer, t.opts.gzipLevel)
+	if err != nil {
+		return s, err
+	}
+	if _, err := gw.Write(data); err != nil {
+		return s, err
+	}
+	if err := gw.Close(); err != nil {
+		return s, err
+	}
+	s.content = buffer.Bytes()
+	s.compressed = true
+	return s, nil
+}
+
+// release releases a spilled file if the storage is not used
+func (s storage) release() {
+	if s.spilled != nil {
+		s.spilled.release()
+	}
+}
+
+func copyContent(data []byte) []byte {
+	max := bufferSize
+	if len(data) > max {
+		max = len(data)
+	}
+	buffer := make([]byte, len(data), max)
+	copy(buffer, data)
+	return buffer
+}
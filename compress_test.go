@@ -0,0 +1,90 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Compression(t *testing.T) {
+	memfs := New(WithCompression())
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	content := strings.Repeat("compressible text\n", 1000)
+	require.NoError(t, memfs.WriteFile("dir/file.txt", []byte(content), 0o644))
+
+	f, err := memfs.dir.getFile(strings.ReplaceAll("dir/file.txt", "/", separator))
+	require.NoError(t, err)
+	assert.True(t, f.compressed)
+	assert.Less(t, len(f.content), len(content))
+
+	info, err := memfs.Stat("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size())
+
+	data, err := memfs.ReadFile("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	data, err = memfs.Bytes("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	data, err = memfs.ReadAt("dir/file.txt", 18, 17)
+	require.NoError(t, err)
+	assert.Equal(t, "compressible text", string(data))
+
+	snapshot := memfs.Snapshot()
+	w, err := memfs.AppendWriter("dir/file.txt")
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "appended")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data, err = memfs.ReadFile("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, content+"appended", string(data))
+
+	data, err = fs.ReadFile(snapshot, "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_CompressionSymlink(t *testing.T) {
+	memfs := New(WithCompression())
+	require.NoError(t, memfs.WriteFile("target.txt", []byte("content"), 0o644))
+	require.NoError(t, memfs.Symlink("target.txt", "link"))
+
+	target, err := memfs.ReadLink("link")
+	require.NoError(t, err)
+	assert.Equal(t, "target.txt", target)
+	data, err := memfs.ReadFile("link")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+}
+
+func Test_CompressionSpill(t *testing.T) {
+	memfs := New(WithCompression(), WithSpill(4, t.TempDir()))
+	defer func() { _ = memfs.Close() }()
+	require.NoError(t, memfs.WriteFile("small.txt", []byte("abc"), 0o644))
+	require.NoError(t, memfs.WriteFile("large.txt", []byte("abcdefgh"), 0o644))
+
+	small, err := memfs.dir.getFile("small.txt")
+	require.NoError(t, err)
+	assert.True(t, small.compressed)
+	large, err := memfs.dir.getFile("large.txt")
+	require.NoError(t, err)
+	assert.False(t, large.compressed)
+	assert.NotNil(t, large.spilled)
+
+	data, err := memfs.ReadFile("large.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefgh", string(data))
+	data, err = memfs.ReadFile("small.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(data))
+}
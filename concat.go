@@ -0,0 +1,83 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// OpenConcat returns a read-only file which streams the content of the named files, in order, as a single logical
+// file. The content of each file is only read once the stream reaches it. Stat reports the sum of the sizes of the
+// files at the time OpenConcat is called, the name of the first file and the latest modification time. An error
+// wrapping fs.ErrNotExist is returned if any of the files is missing.
+func (m *FS) OpenConcat(paths ...string) (fs.File, error) {
+	concat := &concatFile{
+		info: fileinfo{
+			name: "concat",
+			mode: 0o444,
+		},
+	}
+	for i, name := range paths {
+		f, path, err := m.lookupFile("open", name)
+		if err != nil {
+			_ = concat.Close()
+			return nil, err
+		}
+		access, err := f.open()
+		if err != nil {
+			_ = concat.Close()
+			return nil, &fs.PathError{Op: "open", Path: path, Err: err}
+		}
+		info := f.stat()
+		if i == 0 {
+			concat.info.name = info.Name()
+		}
+		if info.ModTime().After(concat.info.modified) {
+			concat.info.modified = info.ModTime()
+		}
+		concat.info.size += info.Size()
+		concat.parts = append(concat.parts, access)
+	}
+	readers := make([]io.Reader, 0, len(concat.parts))
+	for _, part := range concat.parts {
+		readers = append(readers, part)
+	}
+	concat.reader = io.MultiReader(readers...)
+	if !m.dir.tree.openHandle() {
+		_ = concat.Close()
+		return nil, &fs.PathError{Op: "open", Path: concat.info.name, Err: syscall.EMFILE}
+	}
+	concat.tree = m.dir.tree
+	return concat, nil
+}
+
+// concatFile is the fs.File returned by OpenConcat
+type concatFile struct {
+	info   fileinfo
+	parts  []*fileAccess
+	reader io.Reader
+	tree   *tree // set once the handle is counted by OpenHandles
+}
+
+func (c *concatFile) Stat() (fs.FileInfo, error) {
+	return c.info, nil
+}
+
+func (c *concatFile) Read(data []byte) (int, error) {
+	return c.reader.Read(data)
+}
+
+func (c *concatFile) Close() error {
+	var firstErr error
+	for _, part := range c.parts {
+		if err := part.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.parts = nil
+	if c.tree != nil {
+		c.tree.closeHandle()
+		c.tree = nil
+	}
+	return firstErr
+}
@@ -0,0 +1,59 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_OpenConcat(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("parts", 0o700))
+	require.NoError(t, memfs.WriteFile("parts/1", []byte("hello, "), 0o644))
+	require.NoError(t, memfs.WriteFile("parts/2", []byte{}, 0o644))
+	require.NoError(t, memfs.WriteFile("parts/3", []byte("world"), 0o644))
+
+	f, err := memfs.OpenConcat("parts/1", "parts/2", "parts/3")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), info.Size())
+	assert.Equal(t, "1", info.Name())
+
+	// read in small chunks so that reads cross the boundaries between files
+	var data []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := f.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "hello, world", string(data))
+}
+
+func Test_OpenConcatMissing(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("a", []byte("a"), 0o644))
+
+	_, err := memfs.OpenConcat("a", "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_OpenConcatEmpty(t *testing.T) {
+	memfs := New()
+
+	f, err := memfs.OpenConcat()
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	require.NoError(t, f.Close())
+}
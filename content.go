@@ -0,0 +1,216 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType
+const sniffLen = 512
+
+// ContentType returns the MIME type of the named file, as determined by http.DetectContentType from the first
+// 512 bytes of its content.
+func (m *FS) ContentType(name string) (string, error) {
+	head, err := m.readHead("contenttype", name, sniffLen)
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head), nil
+}
+
+// IsBinary reports whether the content of the named file appears to be binary rather than text, based on the
+// MIME type returned by ContentType.
+func (m *FS) IsBinary(name string) (bool, error) {
+	contentType, err := m.ContentType(name)
+	if err != nil {
+		return false, err
+	}
+	return !strings.HasPrefix(contentType, "text/"), nil
+}
+
+// Bytes returns the content of the named file without copying it, for zero-copy access to large files.
+//
+// The returned slice aliases the internal storage of the file: it is read-only, and callers must not modify it.
+// Writes to the file replace its storage rather than modifying it in place, so the slice is not changed by later
+// writes but does become stale. Lazy files have no internal storage, so their content is read via the LazyOpener and returned
+// as a new slice, as is the content of files stored compressed or on disk.
+func (m *FS) Bytes(name string) ([]byte, error) {
+	f, _, err := m.lookupFile("bytes", name)
+	if err != nil {
+		return nil, err
+	}
+	f.RLock()
+	if f.inMemory() {
+		defer f.RUnlock()
+		return f.content[:len(f.content):len(f.content)], nil
+	}
+	f.RUnlock()
+	return f.readAll()
+}
+
+// ReadFileInfo returns a copy of the content of the named file along with its info, looking the file up once.
+// For files held in memory, both are read under a single lock, so they are consistent with each other. An error
+// wrapping ErrIsDir is returned for directories.
+func (m *FS) ReadFileInfo(name string) ([]byte, fs.FileInfo, error) {
+	f, path, err := m.lookupFile("open", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.RLock()
+	if f.inMemory() {
+		defer f.RUnlock()
+		data := make([]byte, len(f.content))
+		copy(data, f.content)
+		return data, f.info, nil
+	}
+	f.RUnlock()
+	data, err := f.readAll()
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	return data, f.stat(), nil
+}
+
+// ReadHead returns a copy of up to the first n bytes of the named file, without reading the rest of its content.
+// The returned slice is shorter than n if the file is. An error wrapping ErrIsDir is returned for directories, and
+// a negative n returns an error wrapping fs.ErrInvalid.
+func (m *FS) ReadHead(name string, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, &fs.PathError{Op: "readhead", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.readHead("readhead", name, n)
+}
+
+// readHead returns a copy of up to the first n bytes of the named file
+func (m *FS) readHead(op string, name string, n int) ([]byte, error) {
+	f, path, err := m.lookupFile(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	if f.inMemory() {
+		defer f.RUnlock()
+		if n > len(f.content) {
+			n = len(f.content)
+		}
+		head := make([]byte, n)
+		copy(head, f.content)
+		return head, nil
+	}
+	f.RUnlock()
+
+	access, err := f.open()
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	defer func() { _ = access.Close() }()
+
+	head, err := ioutil.ReadAll(io.LimitReader(access, int64(n)))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	return head, nil
+}
+
+// ReadAt returns up to length bytes of the content of the named file, starting at offset off. If fewer than length
+// bytes are available, the returned slice is short and the error is io.EOF. A negative offset or length returns
+// an error wrapping fs.ErrInvalid. ReadAt does not require an open handle and is safe for concurrent use.
+func (m *FS) ReadAt(name string, off int64, length int) ([]byte, error) {
+	if off < 0 || length < 0 {
+		return nil, &fs.PathError{Op: "readat", Path: name, Err: fs.ErrInvalid}
+	}
+	f, path, err := m.lookupFile("readat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	if f.inMemory() {
+		defer f.RUnlock()
+		if off >= int64(len(f.content)) {
+			return []byte{}, io.EOF
+		}
+		// compared against the remaining content rather than summed with off, which could overflow
+		end := int64(len(f.content))
+		if int64(length) < end-off {
+			end = off + int64(length)
+		}
+		data := make([]byte, end-off)
+		copy(data, f.content[off:end])
+		if len(data) < length {
+			return data, io.EOF
+		}
+		return data, nil
+	}
+	f.RUnlock()
+
+	access, err := f.open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readat", Path: path, Err: err}
+	}
+	defer func() { _ = access.Close() }()
+	if _, err := io.CopyN(ioutil.Discard, access, off); err != nil {
+		if err == io.EOF {
+			return []byte{}, io.EOF
+		}
+		return nil, &fs.PathError{Op: "readat", Path: path, Err: err}
+	}
+	// the length of compressed and lazy content is not known up front, so the buffer grows as it is read rather than
+	// being sized by the caller
+	data, err := ioutil.ReadAll(io.LimitReader(access, int64(length)))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readat", Path: path, Err: err}
+	}
+	if len(data) < length {
+		return data, io.EOF
+	}
+	return data, nil
+}
+
+// DecodeFile opens the named file and passes its content to decode along with into, closing the file once decode
+// returns. This suits decoders such as json.NewDecoder(r).Decode without tying the package to any format: for
+// example, DecodeFile(name, &cfg, func(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }).
+// An error wrapping fs.ErrNotExist is returned if the file does not exist, and ErrIsDir if it is a directory.
+// Errors returned by decode are wrapped in an *fs.PathError.
+func (m *FS) DecodeFile(name string, into interface{}, decode func(io.Reader, interface{}) error) error {
+	f, err := m.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "decode", Path: name, Err: ErrIsDir}
+	}
+	if err := decode(f, into); err != nil {
+		return &fs.PathError{Op: "decode", Path: name, Err: err}
+	}
+	return nil
+}
+
+// lookupFile resolves the named file for reading, returning an error wrapping ErrIsDir if it is a directory, or the
+// error of the access checker if it denies opening the file
+func (m *FS) lookupFile(op string, name string) (*file, string, error) {
+	path, err := m.realpath(op, name, true)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := m.dir.getFile(path)
+	if err != nil {
+		if _, dirErr := m.dir.getDir(path); dirErr == nil {
+			return nil, "", &fs.PathError{Op: op, Path: path, Err: ErrIsDir}
+		}
+		return nil, "", &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	if err := m.checkAccess("open", path); err != nil {
+		return nil, "", err
+	}
+	return f, path, nil
+}
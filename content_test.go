@@ -0,0 +1,287 @@
+package memoryfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ContentType(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("text.txt", []byte("hello world"), 0o644))
+	require.NoError(t, memfs.WriteFile("page.html", []byte("<!DOCTYPE html><html></html>"), 0o644))
+	require.NoError(t, memfs.WriteFile("image.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), 0o644))
+	require.NoError(t, memfs.WriteFile("blob.bin", []byte{0x00, 0x01, 0x02, 0x03}, 0o644))
+	require.NoError(t, memfs.WriteFile("large.txt", []byte(strings.Repeat("a", 4096)), 0o644))
+
+	for name, expected := range map[string]string{
+		"text.txt":  "text/plain; charset=utf-8",
+		"page.html": "text/html; charset=utf-8",
+		"image.png": "image/png",
+		"blob.bin":  "application/octet-stream",
+		"large.txt": "text/plain; charset=utf-8",
+	} {
+		contentType, err := memfs.ContentType(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, contentType, name)
+	}
+
+	binary, err := memfs.IsBinary("text.txt")
+	require.NoError(t, err)
+	assert.False(t, binary)
+
+	binary, err = memfs.IsBinary("blob.bin")
+	require.NoError(t, err)
+	assert.True(t, binary)
+
+	_, err = memfs.ContentType("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = memfs.IsBinary("dir")
+	assert.ErrorIs(t, err, syscall.EISDIR)
+}
+
+func Test_Bytes(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("file.bin", []byte("hello world"), 0o644))
+	require.NoError(t, memfs.WriteLazyFile("lazy.bin", func() (io.Reader, error) {
+		return strings.NewReader("lazy"), nil
+	}, 0o644))
+
+	data, err := memfs.Bytes("file.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	assert.Equal(t, len(data), cap(data))
+
+	again, err := memfs.Bytes("file.bin")
+	require.NoError(t, err)
+	assert.Same(t, &data[0], &again[0], "Bytes should not copy the content")
+
+	data, err = memfs.Bytes("lazy.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "lazy", string(data))
+
+	_, err = memfs.Bytes("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = memfs.Bytes("dir")
+	assert.ErrorIs(t, err, syscall.EISDIR)
+}
+
+func Test_ReadAt(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("0123456789"), 0o644))
+	require.NoError(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		return strings.NewReader("0123456789"), nil
+	}, 0o644))
+
+	for _, name := range []string{"file.txt", "lazy.txt"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := memfs.ReadAt(name, 2, 3)
+			require.NoError(t, err)
+			assert.Equal(t, "234", string(data))
+
+			data, err = memfs.ReadAt(name, 7, 3)
+			require.NoError(t, err)
+			assert.Equal(t, "789", string(data))
+
+			data, err = memfs.ReadAt(name, 8, 5)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, "89", string(data))
+
+			data, err = memfs.ReadAt(name, 20, 5)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Empty(t, data)
+
+			_, err = memfs.ReadAt(name, -1, 5)
+			assert.ErrorIs(t, err, fs.ErrInvalid)
+
+			_, err = memfs.ReadAt(name, 0, -1)
+			assert.ErrorIs(t, err, fs.ErrInvalid)
+		})
+	}
+
+	_, err := memfs.ReadAt("dir", 0, 1)
+	assert.ErrorIs(t, err, syscall.EISDIR)
+
+	_, err = memfs.ReadAt("missing", 0, 1)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ReadAtOversizedLength(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	for name, memfs := range map[string]*FS{
+		"memory":     New(),
+		"compressed": New(WithCompression()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, memfs.WriteFile("file.txt", []byte(content), 0o644))
+
+			data, err := memfs.ReadAt("file.txt", 0, math.MaxInt)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, content, string(data))
+
+			data, err = memfs.ReadAt("file.txt", 995, math.MaxInt)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, "56789", string(data))
+		})
+	}
+}
+
+func Test_ReadHead(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("0123456789"), 0o644))
+	require.NoError(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		return strings.NewReader("0123456789"), nil
+	}, 0o644))
+
+	for _, name := range []string{"file.txt", "lazy.txt"} {
+		t.Run(name, func(t *testing.T) {
+			head, err := memfs.ReadHead(name, 4)
+			require.NoError(t, err)
+			assert.Equal(t, "0123", string(head))
+
+			head, err = memfs.ReadHead(name, 100)
+			require.NoError(t, err)
+			assert.Equal(t, "0123456789", string(head))
+
+			head, err = memfs.ReadHead(name, 0)
+			require.NoError(t, err)
+			assert.Empty(t, head)
+
+			_, err = memfs.ReadHead(name, -1)
+			assert.ErrorIs(t, err, fs.ErrInvalid)
+		})
+	}
+
+	// the head is a copy, so modifying it does not affect the file
+	head, err := memfs.ReadHead("file.txt", 4)
+	require.NoError(t, err)
+	head[0] = 'x'
+	data, err := memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	_, err = memfs.ReadHead("dir", 1)
+	assert.ErrorIs(t, err, ErrIsDir)
+
+	_, err = memfs.ReadHead("missing", 1)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ReadFileInfo(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/file.txt", []byte("content"), 0o640))
+	require.NoError(t, memfs.WriteLazyFile("dir/lazy.txt", func() (io.Reader, error) {
+		return strings.NewReader("lazy content"), nil
+	}, 0o600))
+
+	data, info, err := memfs.ReadFileInfo("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, "file.txt", info.Name())
+	assert.Equal(t, fs.FileMode(0o640), info.Mode())
+	assert.Equal(t, int64(7), info.Size())
+
+	// the returned content is a copy
+	data[0] = 'C'
+	data, _, err = memfs.ReadFileInfo("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	data, info, err = memfs.ReadFileInfo("dir/lazy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "lazy content", string(data))
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+
+	_, _, err = memfs.ReadFileInfo("dir")
+	assert.ErrorIs(t, err, ErrIsDir)
+
+	_, _, err = memfs.ReadFileInfo("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func benchmarkFS(b *testing.B) *FS {
+	memfs := New()
+	require.NoError(b, memfs.MkdirAll("a/b/c/d", 0o700))
+	require.NoError(b, memfs.WriteFile("a/b/c/d/file.txt", make([]byte, 4096), 0o644))
+	return memfs
+}
+
+func Benchmark_ReadFileInfo(b *testing.B) {
+	memfs := benchmarkFS(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := memfs.ReadFileInfo("a/b/c/d/file.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_StatAndReadFile(b *testing.B) {
+	memfs := benchmarkFS(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := memfs.Stat("a/b/c/d/file.txt"); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := memfs.ReadFile("a/b/c/d/file.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Stat(b *testing.B) {
+	memfs := benchmarkFS(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := memfs.Stat("a/b/c/d/file.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_DecodeFile(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("config.json", []byte(`{"name": "test", "count": 3}`), 0o644))
+	require.NoError(t, memfs.WriteFile("broken.json", []byte(`{"name": `), 0o644))
+
+	decodeJSON := func(r io.Reader, v interface{}) error {
+		return json.NewDecoder(r).Decode(v)
+	}
+
+	var config struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	require.NoError(t, memfs.DecodeFile("config.json", &config, decodeJSON))
+	assert.Equal(t, "test", config.Name)
+	assert.Equal(t, 3, config.Count)
+
+	err := memfs.DecodeFile("broken.json", &config, decodeJSON)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	decodeErr := errors.New("decode failed")
+	err = memfs.DecodeFile("config.json", &config, func(io.Reader, interface{}) error {
+		return decodeErr
+	})
+	assert.ErrorIs(t, err, decodeErr)
+
+	assert.ErrorIs(t, memfs.DecodeFile("missing.json", &config, decodeJSON), fs.ErrNotExist)
+	assert.ErrorIs(t, memfs.DecodeFile("dir", &config, decodeJSON), ErrIsDir)
+}
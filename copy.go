@@ -0,0 +1,133 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// readLinkFS is implemented by filesystems which can report the target of a symbolic link, such as *FS
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// createdInfo is implemented by file info which records a creation time, such as that returned by *FS
+type createdInfo interface {
+	Created() time.Time
+}
+
+// CopyFromFS copies every file and directory in src into the filesystem, reading all content immediately. Unlike
+// CloneFS, the mode and modification time of each entry are taken from src, as is the creation time if src
+// provides one. Symbolic links are copied as links if src can report their targets, and are otherwise skipped,
+// as are any other irregular files. Existing files and symbolic links are replaced: a link is never written through.
+func (m *FS) CopyFromFS(src fs.FS) error {
+	type copied struct {
+		path string
+		info fs.FileInfo
+	}
+	var entries []copied
+
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			if err := m.MkdirAll(name, info.Mode().Perm()); err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			links, ok := src.(readLinkFS)
+			if !ok {
+				return nil
+			}
+			target, err := links.ReadLink(name)
+			if err != nil {
+				return err
+			}
+			if err := m.removeExisting(name, false); err != nil {
+				return err
+			}
+			if err := m.Symlink(target, name); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			data, err := fs.ReadFile(src, name)
+			if err != nil {
+				return err
+			}
+			if err := m.removeExisting(name, true); err != nil {
+				return err
+			}
+			if err := m.WriteFile(name, data, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		entries = append(entries, copied{path: name, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// timestamps are applied once the tree is complete, as creating children updates the parent
+	for _, entry := range entries {
+		path, err := m.realpath("copy", entry.path, false)
+		if err != nil {
+			return err
+		}
+		if err := m.applyInfo(path, entry.info); err != nil {
+			return fmt.Errorf("failed to copy metadata for '%s': %w", entry.path, err)
+		}
+	}
+	return nil
+}
+
+// applyInfo sets the modification and creation times of the entry at the resolved path from info. The mode of
+// directories is also taken from info, as MkdirAll does not change existing directories.
+func (m *FS) applyInfo(path string, info fs.FileInfo) error {
+	created, hasCreated := info.(createdInfo)
+	if f, err := m.dir.getFile(path); err == nil {
+		f.Lock()
+		f.info.modified = info.ModTime()
+		if hasCreated {
+			f.info.created = created.Created()
+		}
+		f.Unlock()
+		return nil
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	d.info.mode = info.Mode().Perm() | fs.ModeDir
+	d.info.modified = info.ModTime()
+	if hasCreated {
+		d.info.created = created.Created()
+	}
+	d.Unlock()
+	return nil
+}
+
+// removeExisting removes the file or symbolic link at name, if there is one, so that it can be replaced by a copy.
+// If linksOnly is true, regular files are left to be overwritten in place.
+func (m *FS) removeExisting(name string, linksOnly bool) error {
+	info, err := m.Lstat(name)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	if linksOnly && info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	return m.Remove(name)
+}
@@ -0,0 +1,96 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CopyFromFS(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	created := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	src := New()
+	require.NoError(t, src.MkdirAll("a/b", 0o750))
+	require.NoError(t, src.WriteFile("a/b/file.txt", []byte("content"), 0o600))
+	require.NoError(t, src.Symlink("b/file.txt", "a/link"))
+	for _, path := range []string{"a", "a/b", "a/b/file.txt"} {
+		require.NoError(t, src.SetModified(path, modified))
+		require.NoError(t, src.Chbtime(path, created))
+	}
+
+	dst := New()
+	require.NoError(t, dst.CopyFromFS(src))
+
+	for _, path := range []string{"a", "a/b", "a/b/file.txt"} {
+		expected, err := src.Stat(path)
+		require.NoError(t, err)
+		actual, err := dst.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Mode(), actual.Mode(), path)
+		assert.True(t, modified.Equal(actual.ModTime()), path)
+		assert.True(t, created.Equal(actual.(interface{ Created() time.Time }).Created()), path)
+	}
+
+	data, err := dst.ReadFile("a/b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	target, err := dst.ReadLink("a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "b/file.txt", target)
+
+	data, err = dst.ReadFile("a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+}
+
+func Test_CopyFromFSIsNotLazy(t *testing.T) {
+	src := New()
+	require.NoError(t, src.WriteFile("file.txt", []byte("original"), 0o644))
+
+	dst := New()
+	require.NoError(t, dst.CopyFromFS(src))
+	require.NoError(t, src.WriteFile("file.txt", []byte("changed"), 0o644))
+
+	data, err := fs.ReadFile(dst, "file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+}
+
+func Test_CopyFromFSReplacesExisting(t *testing.T) {
+	src := New()
+	require.NoError(t, src.MkdirAll("a/b", 0o750))
+	require.NoError(t, src.WriteFile("a/b/file.txt", []byte("content"), 0o600))
+	require.NoError(t, src.Symlink("b/file.txt", "a/link"))
+
+	dst := New()
+	require.NoError(t, dst.MkdirAll("a/b", 0o750))
+	require.NoError(t, dst.WriteFile("a/link", []byte("file in the way"), 0o644))
+	require.NoError(t, dst.WriteFile("other.txt", []byte("other"), 0o644))
+	require.NoError(t, dst.Symlink("../../other.txt", "a/b/file.txt"))
+
+	require.NoError(t, dst.CopyFromFS(src))
+	// copying again replaces the links created by the first copy
+	require.NoError(t, dst.CopyFromFS(src))
+
+	target, err := dst.ReadLink("a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "b/file.txt", target)
+
+	info, err := dst.Lstat("a/b/file.txt")
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	data, err := dst.ReadFile("a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	// the link which was replaced was not written through
+	data, err = dst.ReadFile("other.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+	require.NoError(t, dst.Verify())
+}
@@ -0,0 +1,135 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+	"time"
+)
+
+const defaultDirPerm = 0o755
+
+// WriteFileDeep writes the named file as WriteFile does, first creating any missing parent directories with
+// permissions 0755, or those of their nearest existing ancestor if the filesystem was created with
+// WithInheritedDirMode. If the path would exceed the limit set with WithMaxDepth, ErrTooDeep is returned before any
+// directories are created. If the file cannot be written, for example because of the limit set with WithMaxEntries,
+// the directories created for it are removed again.
+func (m *FS) WriteFileDeep(name string, data []byte, perm fs.FileMode) error {
+	return m.writeFileDeep(name, data, perm, time.Now(), defaultDirPerm)
+}
+
+// writeFileDeep writes the named file as WriteFileAt does, creating any missing parent directories with dirPerm
+// unless the filesystem was created WithInheritedDirMode, and removing them again if the file cannot be written
+func (m *FS) writeFileDeep(name string, data []byte, perm fs.FileMode, mtime time.Time, dirPerm fs.FileMode) error {
+	path, err := m.realpath("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNameLen("write", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("write", path); err != nil {
+		return err
+	}
+	var created []string
+	parent, _ := splitPath(path, m.dir.sep())
+	parentPerm := m.implicitDirPerm(parent, dirPerm)
+	if parent != "" {
+		created = m.missingDirs(parent)
+		if err := m.dir.MkdirAll(parent, parentPerm); err != nil {
+			return &fs.PathError{Op: "write", Path: path, Err: err}
+		}
+	}
+	if err := m.writeFileAt(path, data, perm, mtime); err != nil {
+		m.removeDirs(created)
+		if _, ok := err.(*fs.PathError); ok {
+			return err
+		}
+		return &fs.PathError{Op: "write", Path: path, Err: err}
+	}
+	if len(created) > 0 {
+		m.recordEvent(Event{Op: "mkdir", Path: parent, Mode: parentPerm})
+	}
+	m.recordEvent(Event{Op: "write", Path: path, Data: data, Mode: perm, ModTime: mtime})
+	return nil
+}
+
+// implicitDirPerm returns the permissions for directories created implicitly on the way to the resolved path: those
+// of the nearest existing ancestor if the filesystem was created WithInheritedDirMode, otherwise fallback.
+func (m *FS) implicitDirPerm(path string, fallback fs.FileMode) fs.FileMode {
+	if !m.dir.tree.opts.inheritDirMode {
+		return fallback
+	}
+	ancestor := m.dir
+	for _, part := range strings.Split(path, m.dir.sep()) {
+		sub, err := ancestor.getDir(part)
+		if err != nil {
+			break
+		}
+		ancestor = sub
+	}
+	info, _ := ancestor.Stat()
+	return info.Mode().Perm()
+}
+
+// missingDirs returns the directories along the resolved path, including the path itself, which do not exist,
+// shallowest first
+func (m *FS) missingDirs(path string) []string {
+	if path == "" {
+		return nil
+	}
+	sep := m.dir.sep()
+	parts := strings.Split(path, sep)
+	ancestor := m.dir
+	for i, part := range parts {
+		sub, err := ancestor.getDir(part)
+		if err != nil {
+			missing := make([]string, 0, len(parts)-i)
+			for j := i; j < len(parts); j++ {
+				missing = append(missing, strings.Join(parts[:j+1], sep))
+			}
+			return missing
+		}
+		ancestor = sub
+	}
+	return nil
+}
+
+// removeDirs removes the directories created by an operation which then failed, deepest first, leaving any which
+// are no longer empty
+func (m *FS) removeDirs(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = m.dir.removePath(dirs[i], false)
+	}
+}
+
+// checkNameLen returns an error wrapping ErrNameTooLong if any component of the resolved path is longer than the
+// limit set with WithMaxNameLen
+func (m *FS) checkNameLen(op string, path string) error {
+	max := m.dir.tree.opts.maxNameLen
+	if max <= 0 || path == "" {
+		return nil
+	}
+	for _, part := range strings.Split(path, m.dir.sep()) {
+		if len(part) > max {
+			return &fs.PathError{Op: op, Path: path, Err: ErrNameTooLong}
+		}
+	}
+	return nil
+}
+
+// checkDepth returns an error wrapping ErrTooDeep if the resolved path is deeper than the limit set with
+// WithMaxDepth. Paths are measured from the root of the filesystem, so views returned by Sub share the limit.
+func (m *FS) checkDepth(op string, path string) error {
+	max := m.dir.tree.opts.maxDepth
+	if max <= 0 || path == "" {
+		return nil
+	}
+	depth := len(strings.Split(path, m.dir.sep()))
+	for parent := m.dir.getParent(); parent != nil; parent = parent.getParent() {
+		depth++
+	}
+	if depth > max {
+		return &fs.PathError{Op: op, Path: path, Err: ErrTooDeep}
+	}
+	return nil
+}
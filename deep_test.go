@@ -0,0 +1,141 @@
+package memoryfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteFileDeep(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFileDeep("a/b/c/file.txt", []byte("deep"), 0o644))
+
+	data, err := memfs.ReadFile("a/b/c/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "deep", string(data))
+
+	info, err := memfs.Stat("a/b")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, "drwxr-xr-x", info.Mode().String())
+
+	require.NoError(t, memfs.WriteFileDeep("top.txt", []byte("top"), 0o644))
+}
+
+func Test_WriteFileDeepInheritedDirMode(t *testing.T) {
+	memfs := New(WithInheritedDirMode())
+	require.NoError(t, memfs.MkdirAll("a", 0o750))
+	require.NoError(t, memfs.WriteFileDeep("a/b/c/file.txt", []byte("deep"), 0o644))
+
+	for _, name := range []string{"a/b", "a/b/c"} {
+		info, err := memfs.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0o750), info.Mode().Perm(), name)
+	}
+
+	// explicit modes still win
+	require.NoError(t, memfs.MkdirAll("a/explicit", 0o700))
+	info, err := memfs.Stat("a/explicit")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o700), info.Mode().Perm())
+
+	// without an existing ancestor other than the root, the mode of the root is inherited
+	require.NoError(t, memfs.WriteFileDeep("x/file.txt", nil, 0o644))
+	info, err = memfs.Stat("x")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o700), info.Mode().Perm())
+
+	// the default is unchanged
+	memfs = New()
+	require.NoError(t, memfs.MkdirAll("a", 0o750))
+	require.NoError(t, memfs.WriteFileDeep("a/b/file.txt", nil, 0o644))
+	info, err = memfs.Stat("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o755), info.Mode().Perm())
+}
+
+func Test_ReadTarInheritedDirMode(t *testing.T) {
+	memfs := New(WithInheritedDirMode())
+	require.NoError(t, memfs.MkdirAll("a", 0o750))
+	require.NoError(t, memfs.ReadTar(bytes.NewReader(buildTar(t, []tarEntry{
+		{name: "a/b/c/file.txt", typeflag: tar.TypeReg, content: "content"},
+		{name: "d/", typeflag: tar.TypeDir, mode: 0o711},
+	}))))
+
+	info, err := memfs.Stat("a/b/c")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o750), info.Mode().Perm())
+	info, err = memfs.Stat("d")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o711), info.Mode().Perm())
+}
+
+func Test_WriteFileDeepTooDeep(t *testing.T) {
+	memfs := New(WithMaxDepth(3))
+	require.NoError(t, memfs.WriteFileDeep("a/b/file.txt", []byte("ok"), 0o644))
+
+	err := memfs.WriteFileDeep("x/y/z/file.txt", []byte("too deep"), 0o644)
+	assert.ErrorIs(t, err, ErrTooDeep)
+
+	entries, err := memfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a", entries[0].Name())
+
+	err = memfs.WriteFileDeep("a/b/c/file.txt", []byte("too deep"), 0o644)
+	assert.ErrorIs(t, err, ErrTooDeep)
+	_, err = memfs.Stat("a/b/c")
+	assert.Error(t, err)
+}
+
+func Test_MaxDepth(t *testing.T) {
+	memfs := New(WithMaxDepth(2))
+
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	assert.ErrorIs(t, memfs.MkdirAll("a/b/c", 0o700), ErrTooDeep)
+	assert.ErrorIs(t, memfs.WriteFile("a/b/file.txt", nil, 0o644), ErrTooDeep)
+	assert.ErrorIs(t, memfs.Symlink("a", "a/b/link"), ErrTooDeep)
+	require.NoError(t, memfs.WriteFile("f", nil, 0o644))
+	assert.ErrorIs(t, memfs.Rename("f", "a/b/f2"), ErrTooDeep)
+	_, err := memfs.AppendWriter("a/b/f")
+	assert.ErrorIs(t, err, ErrTooDeep)
+	_, err = memfs.Stat("a/b/f")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, memfs.Rename("f", "a/f2"))
+
+	sub, err := memfs.Sub("a")
+	require.NoError(t, err)
+	assert.ErrorIs(t, sub.(*FS).WriteFile("b/file.txt", nil, 0o644), ErrTooDeep)
+	assert.NoError(t, sub.(*FS).WriteFile("file.txt", nil, 0o644))
+}
+
+func Test_MaxNameLen(t *testing.T) {
+	memfs := New(WithMaxNameLen(255))
+	long := strings.Repeat("x", 300)
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/file.txt", nil, 0o644))
+
+	assert.ErrorIs(t, memfs.WriteFile("dir/"+long, nil, 0o644), ErrNameTooLong)
+	assert.ErrorIs(t, memfs.WriteFileDeep("dir/"+long+"/file.txt", nil, 0o644), ErrNameTooLong)
+	assert.ErrorIs(t, memfs.MkdirAll("dir/a/"+long+"/b", 0o700), syscall.ENAMETOOLONG)
+	assert.ErrorIs(t, memfs.Rename("dir/file.txt", "dir/"+long), ErrNameTooLong)
+	assert.ErrorIs(t, memfs.Symlink("file.txt", "dir/"+long), ErrNameTooLong)
+	_, err := memfs.AppendWriter("dir/" + long)
+	assert.ErrorIs(t, err, ErrNameTooLong)
+
+	// nothing was created or moved
+	entries, err := memfs.ReadDir("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "file.txt", entries[0].Name())
+
+	// the limit applies to each component rather than the whole path
+	name := strings.Repeat("y", 255)
+	require.NoError(t, memfs.WriteFileDeep(name+"/"+name+"/file.txt", nil, 0o644))
+}
@@ -2,22 +2,33 @@ package memoryfs
 
 import (
 	"fmt"
-	"io"
 	"io/fs"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-var separator = string(filepath.Separator)
+// separator is the default path separator, see WithSeparator
+const separator = string(filepath.Separator)
 
 type dir struct {
 	sync.RWMutex
-	info  fileinfo
-	dirs  map[string]*dir
-	files map[string]*file
+	info   fileinfo
+	tree   *tree
+	parent atomic.Value // *dir, nil for the root
+	dirs   map[string]*dir
+	files  map[string]*file
+	source *mountSource // children not yet loaded from a mount, see populate
+
+	// sizeGen is bumped whenever the content of the subtree changes, invalidating the cached size
+	sizeGen    uint64
+	sizeMu     sync.Mutex
+	sizeValid  uint64 // sizeGen+1 at the time cachedSize was computed, 0 if never computed
+	cachedSize int64
 }
 
 func (d *dir) Open(name string) (fs.File, error) {
@@ -27,14 +38,22 @@ func (d *dir) Open(name string) (fs.File, error) {
 	}
 
 	if f, err := d.getFile(name); err == nil {
-		return f.open()
+		access, err := f.open()
+		if err != nil {
+			return nil, err
+		}
+		if !d.tree.openHandle() {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EMFILE}
+		}
+		access.tree = d.tree
+		return access, nil
 	}
 
-	if f, err := d.getDir(name); err == nil {
-		return f, nil
+	sub, err := d.getDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
 	}
-
-	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	return sub, nil
 }
 
 func (d *dir) Remove(name string) error {
@@ -60,24 +79,44 @@ func (d *dir) Stat() (fs.FileInfo, error) {
 }
 
 func (d *dir) removePath(name string, recursive bool) error {
+	d.populate()
 
-	parts := strings.Split(name, separator)
+	parts := strings.Split(name, d.sep())
 	if len(parts) == 1 {
 		d.RLock()
-		_, ok := d.files[name]
+		f, ok := d.files[name]
 		d.RUnlock()
 		if ok {
+			if f.isImmutable() {
+				return ErrImmutable
+			}
+			d.Lock()
 			delete(d.files, name)
+			d.touch(time.Now())
+			d.Unlock()
+			d.tree.releaseEntries(1)
+			if f.stat().Mode().IsRegular() {
+				d.tree.releaseFile()
+			}
+			f.discard()
+			d.invalidateSize()
 			return nil
 		}
 
 		if sub, err := d.getDir(parts[0]); err == nil {
+			sub.populate()
 			d.Lock()
 			defer d.Unlock()
 			if len(sub.dirs) == 0 && len(sub.files) == 0 {
 				delete(d.dirs, parts[0])
+				d.tree.releaseEntries(1)
+				d.touch(time.Now())
+				d.invalidateSize()
 				return nil
 			} else if recursive {
+				if sub.containsImmutable() {
+					return ErrImmutable
+				}
 				for _, s := range sub.dirs {
 					sub.removePath(s.info.name, recursive)
 				}
@@ -85,6 +124,9 @@ func (d *dir) removePath(name string, recursive bool) error {
 					sub.removePath(f.info.name, recursive)
 				}
 				delete(d.dirs, parts[0])
+				d.tree.releaseEntries(1)
+				d.touch(time.Now())
+				d.invalidateSize()
 				return nil
 			}
 			return fs.ErrInvalid
@@ -97,57 +139,82 @@ func (d *dir) removePath(name string, recursive bool) error {
 		return err
 	}
 
-	return sub.removePath(strings.Join(parts[1:], separator), recursive)
+	return sub.removePath(strings.Join(parts[1:], d.sep()), recursive)
 }
 
 func (d *dir) getFile(name string) (*file, error) {
-
-	parts := strings.Split(name, separator)
-	if len(parts) == 1 {
-		d.RLock()
-		f, ok := d.files[name]
-		d.RUnlock()
-		if ok {
-			return f, nil
-		}
-		return nil, fs.ErrNotExist
-	}
-
-	sub, err := d.getDir(parts[0])
+	parentPath, base := splitPath(name, d.sep())
+	parent, err := d.getDir(parentPath)
 	if err != nil {
 		return nil, err
 	}
+	parent.populate()
 
-	return sub.getFile(strings.Join(parts[1:], separator))
+	parent.RLock()
+	f, ok := parent.files[base]
+	parent.RUnlock()
+	if ok {
+		return f, nil
+	}
+	return nil, fs.ErrNotExist
 }
 
+// getDir returns the directory at name beneath d. The path is descended one directory at a time rather than
+// recursively, so looking up deep paths does not build intermediate strings.
 func (d *dir) getDir(name string) (*dir, error) {
-
 	if name == "" {
 		return d, nil
 	}
+	current := d
+	for _, part := range strings.Split(name, d.sep()) {
+		current.populate()
+		current.RLock()
+		sub, ok := current.dirs[part]
+		current.RUnlock()
+		if !ok {
+			return nil, current.missingDir(part)
+		}
+		current = sub
+	}
+	return current, nil
+}
 
-	parts := strings.Split(name, separator)
+// touch records that an entry of d has been created, removed or renamed by updating its modification time, unless
+// the filesystem was created WithStaticDirTimes. It must be called with d locked.
+func (d *dir) touch(now time.Time) {
+	if !d.tree.opts.staticDirTimes {
+		d.info.modified = now
+	}
+}
 
+// missingDir returns the error for a directory name which is not present in d: ErrNotDir if it names a file
+// instead, otherwise fs.ErrNotExist
+func (d *dir) missingDir(name string) error {
 	d.RLock()
-	f, ok := d.dirs[parts[0]]
+	_, isFile := d.files[name]
 	d.RUnlock()
-	if ok {
-		return f.getDir(strings.Join(parts[1:], separator))
+	if isFile {
+		return ErrNotDir
 	}
-
-	return nil, fs.ErrNotExist
+	return fs.ErrNotExist
 }
 
 func (d *dir) ReadDir(name string) ([]fs.DirEntry, error) {
+	d.populate()
 	if name == "" {
 		var entries []fs.DirEntry
 		d.RLock()
-		for _, file := range d.files {
+		for name, file := range d.files {
+			if d.hidden(name) {
+				continue
+			}
 			stat := file.stat()
 			entries = append(entries, stat.(fs.DirEntry))
 		}
-		for _, dir := range d.dirs {
+		for name, dir := range d.dirs {
+			if d.hidden(name) {
+				continue
+			}
 			stat, _ := dir.Stat()
 			entries = append(entries, stat.(fs.DirEntry))
 		}
@@ -156,19 +223,89 @@ func (d *dir) ReadDir(name string) ([]fs.DirEntry, error) {
 		return entries, nil
 	}
 
-	parts := strings.Split(name, separator)
+	parts := strings.Split(name, d.sep())
 
 	d.RLock()
 	dir, ok := d.dirs[parts[0]]
 	d.RUnlock()
 	if !ok {
-		return nil, fs.ErrNotExist
+		return nil, d.missingDir(parts[0])
 	}
-	return dir.ReadDir(strings.Join(parts[1:], separator))
+	return dir.ReadDir(strings.Join(parts[1:], d.sep()))
+}
+
+// readDirFilter returns the sorted entries of d for which keep returns true
+func (d *dir) readDirFilter(keep func(fs.DirEntry) bool) []fs.DirEntry {
+	d.populate()
+	var entries []fs.DirEntry
+	d.RLock()
+	for name, file := range d.files {
+		if d.hidden(name) {
+			continue
+		}
+		if entry := file.stat().(fs.DirEntry); keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	for name, dir := range d.dirs {
+		if d.hidden(name) {
+			continue
+		}
+		stat, _ := dir.Stat()
+		if entry := stat.(fs.DirEntry); keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	d.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// hidden reports whether an entry of d named name is omitted from listings, see WithSkipHidden
+func (d *dir) hidden(name string) bool {
+	return d.tree.opts.skipHidden && strings.HasPrefix(name, ".")
+}
+
+// visibleNames returns the names which are not hidden, see WithSkipHidden
+func (d *dir) visibleNames(names []string) []string {
+	if !d.tree.opts.skipHidden {
+		return names
+	}
+	visible := names[:0]
+	for _, name := range names {
+		if !d.hidden(name) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+func (d *dir) dirNames() []string {
+	d.populate()
+	d.RLock()
+	names := make([]string, 0, len(d.dirs))
+	for name := range d.dirs {
+		names = append(names, name)
+	}
+	d.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (d *dir) fileNames() []string {
+	d.populate()
+	d.RLock()
+	names := make([]string, 0, len(d.files))
+	for name := range d.files {
+		names = append(names, name)
+	}
+	d.RUnlock()
+	sort.Strings(names)
+	return names
 }
 
 func (d *dir) Read(_ []byte) (int, error) {
-	return 0, fs.ErrInvalid
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: ErrIsDir}
 }
 
 func (d *dir) Close() error {
@@ -176,36 +313,60 @@ func (d *dir) Close() error {
 }
 
 func (d *dir) MkdirAll(path string, perm fs.FileMode) error {
-	parts := strings.Split(path, separator)
+	return d.mkdirAll(path, perm, false)
+}
+
+// mkdirAll creates the directories along path. Once a missing directory is found, entries are reserved for it and
+// all of its descendants at once, so that either every missing directory is created or none are, and reserved is
+// set for the descendants.
+func (d *dir) mkdirAll(path string, perm fs.FileMode, reserved bool) error {
+	d.populate()
+	parts := strings.Split(path, d.sep())
 
 	if path == "" {
 		return nil
 	}
 
-	d.RLock()
-	_, ok := d.files[parts[0]]
-	d.RUnlock()
-	if ok {
+	// the check is made under the same lock as the creation, so a file and a directory can never share a name
+	d.Lock()
+	if _, ok := d.files[parts[0]]; ok {
+		d.Unlock()
+		if reserved {
+			d.tree.releaseEntries(int64(len(parts)))
+		}
 		return fs.ErrExist
 	}
-
-	d.Lock()
 	if perm&fs.ModeDir == 0 {
 		perm |= fs.ModeDir
 	}
+	now := time.Now()
 	if _, ok := d.dirs[parts[0]]; !ok {
-		d.dirs[parts[0]] = &dir{
+		if !reserved {
+			if !d.tree.reserveEntries(int64(len(parts))) {
+				d.Unlock()
+				return ErrTooManyEntries
+			}
+			reserved = true
+		}
+		sub := &dir{
 			info: fileinfo{
 				name:     parts[0],
 				size:     0x100,
-				modified: time.Now(),
+				modified: now,
+				created:  now,
 				mode:     perm,
 			},
+			tree:  d.tree,
 			dirs:  map[string]*dir{},
 			files: map[string]*file{},
 		}
+		sub.setParent(d)
+		d.dirs[parts[0]] = sub
+		d.touch(now)
+	} else if reserved {
+		// created concurrently after the reservation was made
+		d.tree.releaseEntries(1)
 	}
-	d.info.modified = time.Now()
 	d.Unlock()
 
 	if len(parts) == 1 {
@@ -214,46 +375,77 @@ func (d *dir) MkdirAll(path string, perm fs.FileMode) error {
 
 	d.RLock()
 	defer d.RUnlock()
-	return d.dirs[parts[0]].MkdirAll(strings.Join(parts[1:], separator), perm)
+	return d.dirs[parts[0]].mkdirAll(strings.Join(parts[1:], d.sep()), perm, reserved)
 }
 
 func (d *dir) WriteFile(path string, data []byte, perm fs.FileMode) error {
-	parts := strings.Split(path, separator)
+	return d.writeFileAt(path, data, perm, time.Now())
+}
+
+// writeFileAt writes the file at path, recording modified as its modification time
+func (d *dir) writeFileAt(path string, data []byte, perm fs.FileMode, modified time.Time) error {
+	d.populate()
+	parts := strings.Split(path, d.sep())
 
 	if perm&fs.ModeDir != 0 {
 		return fmt.Errorf("invalid perm: %v", perm)
 	}
 
 	if len(parts) == 1 {
-		max := bufferSize
-		if len(data) > max {
-			max = len(data)
+		// content is copied, compressed or written to disk before taking the lock
+		stored, err := d.tree.store(data, perm)
+		if err != nil {
+			return err
 		}
-		buffer := make([]byte, len(data), max)
-		copy(buffer, data)
 		d.Lock()
 		defer d.Unlock()
+		if _, ok := d.dirs[parts[0]]; ok {
+			stored.release()
+			return fs.ErrExist
+		}
 		if existing, ok := d.files[parts[0]]; ok {
-			if err := existing.overwrite(buffer, perm); err != nil {
+			if d.tree.opts.noClobber {
+				stored.release()
+				return fs.ErrExist
+			}
+			if existing.isImmutable() {
+				stored.release()
+				return ErrImmutable
+			}
+			if !d.tree.replaceFile(existing.stat().Mode(), perm) {
+				stored.release()
+				return ErrTooManyFiles
+			}
+			if err := existing.overwrite(data, stored, perm, modified); err != nil {
 				return err
 			}
 		} else {
+			if !d.tree.reserveEntries(1) {
+				stored.release()
+				return ErrTooManyEntries
+			}
+			if !d.tree.replaceFile(fs.ModeIrregular, perm) {
+				d.tree.releaseEntries(1)
+				stored.release()
+				return ErrTooManyFiles
+			}
 			newFile := &file{
 				info: fileinfo{
 					name:     parts[0],
-					size:     int64(len(buffer)),
-					modified: time.Now(),
+					size:     stored.size,
+					modified: modified,
+					created:  time.Now(),
 					mode:     perm,
 				},
-				content: buffer,
-			}
-			newFile.opener = func() (io.Reader, error) {
-				return &lazyAccess{
-					file: newFile,
-				}, nil
+				content:    stored.content,
+				spilled:    stored.spilled,
+				compressed: stored.compressed,
 			}
+			newFile.opener = newFile.openMemory
 			d.files[parts[0]] = newFile
+			d.touch(time.Now())
 		}
+		d.invalidateSize()
 		return nil
 	}
 
@@ -261,18 +453,19 @@ func (d *dir) WriteFile(path string, data []byte, perm fs.FileMode) error {
 	_, ok := d.dirs[parts[0]]
 	d.RUnlock()
 	if !ok {
-		return fs.ErrNotExist
+		return d.missingDir(parts[0])
 	}
 
 	d.RLock()
 	defer d.RUnlock()
-	return d.dirs[parts[0]].WriteFile(strings.Join(parts[1:], separator), data, perm)
+	return d.dirs[parts[0]].writeFileAt(strings.Join(parts[1:], d.sep()), data, perm, modified)
 }
 
 func (d *dir) glob(pattern string) ([]string, error) {
 
+	d.populate()
 	var entries []string
-	parts := strings.Split(pattern, separator)
+	parts := strings.Split(pattern, d.sep())
 
 	d.RLock()
 	defer d.RUnlock()
@@ -283,12 +476,12 @@ func (d *dir) glob(pattern string) ([]string, error) {
 			if len(parts) == 1 {
 				entries = append(entries, name)
 			} else {
-				subEntries, err := dir.glob(strings.Join(parts[1:], separator))
+				subEntries, err := dir.glob(strings.Join(parts[1:], d.sep()))
 				if err != nil {
 					return nil, err
 				}
 				for _, sub := range subEntries {
-					entries = append(entries, strings.Join([]string{name, sub}, separator))
+					entries = append(entries, joinPath(name, sub, d.sep()))
 				}
 			}
 		}
@@ -308,7 +501,8 @@ func (d *dir) glob(pattern string) ([]string, error) {
 }
 
 func (d *dir) WriteLazyFile(path string, opener LazyOpener, perm fs.FileMode) error {
-	parts := strings.Split(path, separator)
+	d.populate()
+	parts := strings.Split(path, d.sep())
 
 	if perm&fs.ModeDir != 0 {
 		return fmt.Errorf("invalid perm: %v", perm)
@@ -317,15 +511,50 @@ func (d *dir) WriteLazyFile(path string, opener LazyOpener, perm fs.FileMode) er
 	if len(parts) == 1 {
 		d.Lock()
 		defer d.Unlock()
+		now := time.Now()
+		created := now
+		previous := fs.ModeIrregular
+		if _, ok := d.dirs[parts[0]]; ok {
+			return fs.ErrExist
+		}
+		existing, ok := d.files[parts[0]]
+		if ok {
+			if d.tree.opts.noClobber {
+				return fs.ErrExist
+			}
+			if existing.isImmutable() {
+				return ErrImmutable
+			}
+			created = existing.stat().(fileinfo).created
+			previous = existing.stat().Mode()
+		}
+		if !ok && !d.tree.reserveEntries(1) {
+			return ErrTooManyEntries
+		}
+		if !d.tree.replaceFile(previous, perm) {
+			if !ok {
+				d.tree.releaseEntries(1)
+			}
+			return ErrTooManyFiles
+		}
+		if ok {
+			existing.discard()
+		}
 		d.files[parts[0]] = &file{
 			info: fileinfo{
 				name:     parts[0],
 				size:     0,
-				modified: time.Now(),
+				modified: now,
+				created:  created,
 				mode:     perm,
 			},
 			opener: opener,
+			lazy:   true,
+		}
+		if !ok {
+			d.touch(now)
 		}
+		d.invalidateSize()
 		return nil
 	}
 
@@ -333,10 +562,142 @@ func (d *dir) WriteLazyFile(path string, opener LazyOpener, perm fs.FileMode) er
 	_, ok := d.dirs[parts[0]]
 	d.RUnlock()
 	if !ok {
-		return fs.ErrNotExist
+		return d.missingDir(parts[0])
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+	return d.dirs[parts[0]].WriteLazyFile(strings.Join(parts[1:], d.sep()), opener, perm)
+}
+
+// sep returns the path separator of the filesystem
+func (d *dir) sep() string {
+	return d.tree.opts.separator
+}
+
+func (d *dir) getParent() *dir {
+	parent, _ := d.parent.Load().(*dir)
+	return parent
+}
+
+func (d *dir) setParent(parent *dir) {
+	d.parent.Store(parent)
+}
+
+// isAncestorOf reports whether d is a (non-immediate or immediate) parent of other
+func (d *dir) isAncestorOf(other *dir) bool {
+	for p := other.getParent(); p != nil; p = p.getParent() {
+		if p == d {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateSize marks the cached size of this directory and all of its ancestors as stale
+func (d *dir) invalidateSize() {
+	for p := d; p != nil; p = p.getParent() {
+		atomic.AddUint64(&p.sizeGen, 1)
 	}
+}
 
+// size returns the sum of the sizes of all files within the directory tree, using a cached value where possible
+func (d *dir) size() int64 {
+	gen := atomic.LoadUint64(&d.sizeGen)
+	d.sizeMu.Lock()
+	if d.sizeValid == gen+1 {
+		defer d.sizeMu.Unlock()
+		return d.cachedSize
+	}
+	d.sizeMu.Unlock()
+
+	d.populate()
+	var total int64
+	d.RLock()
+	for _, f := range d.files {
+		total += f.stat().Size()
+	}
+	for _, sub := range d.dirs {
+		total += sub.size()
+	}
+	d.RUnlock()
+
+	d.sizeMu.Lock()
+	defer d.sizeMu.Unlock()
+	// only cache the result if nothing changed while it was being computed
+	if atomic.LoadUint64(&d.sizeGen) == gen {
+		d.sizeValid = gen + 1
+		d.cachedSize = total
+	}
+	return total
+}
+
+// walk calls fn for d and then every directory and file beneath it, in lexical order with parents visited before
+// their children. For directories f is nil, for files sub is nil. Returning fs.SkipDir for a directory skips its
+// contents.
+func (d *dir) walk(path string, fn func(path string, sub *dir, f *file) error) error {
+	if err := fn(path, d, nil); err != nil {
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	d.populate()
+	d.RLock()
+	names := make([]string, 0, len(d.dirs)+len(d.files))
+	dirs := make(map[string]*dir, len(d.dirs))
+	files := make(map[string]*file, len(d.files))
+	for name, sub := range d.dirs {
+		names = append(names, name)
+		dirs[name] = sub
+	}
+	for name, f := range d.files {
+		names = append(names, name)
+		files[name] = f
+	}
+	d.RUnlock()
+	sort.Strings(names)
+
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		child := joinPath(path, name, d.sep())
+		if f, ok := files[name]; ok {
+			if err := fn(child, nil, f); err != nil {
+				return err
+			}
+		}
+		if sub, ok := dirs[name]; ok {
+			if err := sub.walk(child, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the directory structure rooted at d, belonging to t. File content is shared rather
+// than copied, as it is never modified in place.
+func (d *dir) clone(t *tree, parent *dir) *dir {
 	d.RLock()
 	defer d.RUnlock()
-	return d.dirs[parts[0]].WriteLazyFile(strings.Join(parts[1:], separator), opener, perm)
+	c := &dir{
+		info:   d.info,
+		tree:   t,
+		source: d.source,
+		dirs:   make(map[string]*dir, len(d.dirs)),
+		files:  make(map[string]*file, len(d.files)),
+	}
+	if parent != nil {
+		c.setParent(parent)
+	}
+	for name, f := range d.files {
+		c.files[name] = f.clone()
+	}
+	for name, sub := range d.dirs {
+		c.dirs[name] = sub.clone(t, c)
+	}
+	return c
 }
@@ -0,0 +1,106 @@
+package memoryfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// SubtreeEqual reports whether the named directories have identical structure, i.e. the same names, modes and file
+// content throughout. The modes of the two directories themselves are not compared. An error is returned if either
+// directory is missing.
+func (m *FS) SubtreeEqual(pathA, pathB string) (bool, error) {
+	diff, err := m.SubtreeDiff(pathA, pathB)
+	if err != nil {
+		return false, err
+	}
+	return diff == "", nil
+}
+
+// SubtreeDiff compares the named directories as SubtreeEqual does, returning the first differing path relative
+// to the two directories, or "" if they are equal. Paths are compared in lexical order.
+func (m *FS) SubtreeDiff(pathA, pathB string) (string, error) {
+	a, _, err := m.walkRoot("subtreediff", pathA)
+	if err != nil {
+		return "", err
+	}
+	b, _, err := m.walkRoot("subtreediff", pathB)
+	if err != nil {
+		return "", err
+	}
+	return diffDirs("", a, b)
+}
+
+// diffDirs returns the first path within a and b which differs, or "" if none does
+func diffDirs(path string, a *dir, b *dir) (string, error) {
+
+	filesA, filesB := a.fileNames(), b.fileNames()
+	for _, name := range mergeNames(filesA, filesB) {
+		fileA, errA := a.getFile(name)
+		fileB, errB := b.getFile(name)
+		if errA != nil || errB != nil {
+			return joinPath(path, name, a.sep()), nil
+		}
+		equal, err := equalFiles(fileA, fileB)
+		if err != nil {
+			return "", &fs.PathError{Op: "subtreediff", Path: joinPath(path, name, a.sep()), Err: err}
+		}
+		if !equal {
+			return joinPath(path, name, a.sep()), nil
+		}
+	}
+
+	for _, name := range mergeNames(a.dirNames(), b.dirNames()) {
+		subA, errA := a.getDir(name)
+		subB, errB := b.getDir(name)
+		if errA != nil || errB != nil {
+			return joinPath(path, name, a.sep()), nil
+		}
+		infoA, _ := subA.Stat()
+		infoB, _ := subB.Stat()
+		if infoA.Mode() != infoB.Mode() {
+			return joinPath(path, name, a.sep()), nil
+		}
+		if diff, err := diffDirs(joinPath(path, name, a.sep()), subA, subB); err != nil || diff != "" {
+			return diff, err
+		}
+	}
+
+	return "", nil
+}
+
+// equalFiles reports whether two files have the same mode and content
+func equalFiles(a *file, b *file) (bool, error) {
+	if a.stat().Mode() != b.stat().Mode() {
+		return false, nil
+	}
+	if a.isSymlink() {
+		return a.linkTarget() == b.linkTarget(), nil
+	}
+	contentA, err := a.readAll()
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	contentB, err := b.readAll()
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	return bytes.Equal(contentA, contentB), nil
+}
+
+// mergeNames returns the sorted union of two sorted name lists
+func mergeNames(a []string, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	names := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, name := range list {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
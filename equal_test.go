@@ -0,0 +1,96 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildSubtree(t *testing.T, memfs *FS, root string) {
+	require.NoError(t, memfs.MkdirAll(root+"/x/y", 0o700))
+	require.NoError(t, memfs.WriteFile(root+"/a.txt", []byte("a"), 0o644))
+	require.NoError(t, memfs.WriteFile(root+"/x/b.txt", []byte("b"), 0o644))
+	require.NoError(t, memfs.WriteFile(root+"/x/y/c.txt", []byte("c"), 0o600))
+}
+
+func Test_SubtreeEqual(t *testing.T) {
+	memfs := New()
+	buildSubtree(t, memfs, "one")
+	buildSubtree(t, memfs, "two")
+
+	equal, err := memfs.SubtreeEqual("one", "two")
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	require.NoError(t, memfs.WriteFile("two/x/y/c.txt", []byte("changed"), 0o600))
+	equal, err = memfs.SubtreeEqual("one", "two")
+	require.NoError(t, err)
+	assert.False(t, equal)
+
+	diff, err := memfs.SubtreeDiff("one", "two")
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll("x/y/c.txt", "/", separator), diff)
+}
+
+func Test_SubtreeDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(memfs *FS) error
+		diff   string
+	}{
+		{
+			name: "mode",
+			change: func(memfs *FS) error {
+				return memfs.WriteFile("two/a.txt", []byte("a"), 0o600)
+			},
+			diff: "a.txt",
+		},
+		{
+			name: "extra file",
+			change: func(memfs *FS) error {
+				return memfs.WriteFile("two/x/extra.txt", nil, 0o644)
+			},
+			diff: "x/extra.txt",
+		},
+		{
+			name: "missing directory",
+			change: func(memfs *FS) error {
+				return memfs.RemoveAll("two/x/y")
+			},
+			diff: "x/y",
+		},
+		{
+			name: "file replaced by directory",
+			change: func(memfs *FS) error {
+				if err := memfs.Remove("two/a.txt"); err != nil {
+					return err
+				}
+				return memfs.MkdirAll("two/a.txt", 0o700)
+			},
+			diff: "a.txt",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			memfs := New()
+			buildSubtree(t, memfs, "one")
+			buildSubtree(t, memfs, "two")
+			require.NoError(t, test.change(memfs))
+
+			diff, err := memfs.SubtreeDiff("one", "two")
+			require.NoError(t, err)
+			assert.Equal(t, strings.ReplaceAll(test.diff, "/", separator), diff)
+		})
+	}
+}
+
+func Test_SubtreeEqualMissing(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("one", 0o700))
+
+	_, err := memfs.SubtreeEqual("one", "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,53 @@
+package memoryfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ErrUnsafePath is returned when a path from an untrusted source, such as an archive entry, would escape the root of the filesystem
+var ErrUnsafePath = errors.New("unsafe path")
+
+// ErrSymlinkLoop is returned when resolving a path encounters too many levels of symbolic links, or when a walk
+// which follows symbolic links would revisit one of its own ancestors
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// ErrIsDir is returned when an operation which requires a file, such as reading content, is given a directory.
+// It matches syscall.EISDIR with errors.Is.
+var ErrIsDir error = &errnoError{msg: "is a directory", errno: syscall.EISDIR}
+
+// ErrNotDir is returned when a path treats a file as a directory, for example "file.txt/child". It matches
+// syscall.ENOTDIR with errors.Is.
+var ErrNotDir error = &errnoError{msg: "not a directory", errno: syscall.ENOTDIR}
+
+// ErrTooDeep is returned when creating a file or directory would exceed the depth limit set with WithMaxDepth
+var ErrTooDeep = errors.New("path too deep")
+
+// ErrNameTooLong is returned when a path contains a component longer than the limit set with WithMaxNameLen. It
+// matches syscall.ENAMETOOLONG with errors.Is.
+var ErrNameTooLong error = &errnoError{msg: "file name too long", errno: syscall.ENAMETOOLONG}
+
+// ErrImmutable is returned when an operation would modify, remove or rename a file marked with SetImmutable. It
+// matches syscall.EPERM and fs.ErrPermission with errors.Is.
+var ErrImmutable error = &errnoError{msg: "operation not permitted on immutable file", errno: syscall.EPERM}
+
+// ErrTooManyFiles is returned when creating a file would exceed the limit set with WithMaxFiles
+var ErrTooManyFiles = errors.New("too many files")
+
+// ErrTooManyEntries is returned when creating a file, directory or symbolic link would exceed the limit set with
+// WithMaxEntries
+var ErrTooManyEntries = errors.New("too many entries")
+
+// errnoError is an error which is also recognised as the equivalent system error number
+type errnoError struct {
+	msg   string
+	errno syscall.Errno
+}
+
+func (e *errnoError) Error() string {
+	return e.msg
+}
+
+func (e *errnoError) Is(target error) bool {
+	return target == e.errno || e.errno.Is(target)
+}
@@ -2,9 +2,12 @@ package memoryfs
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
+	"io/ioutil"
 	"sync"
 	"time"
 )
@@ -14,11 +17,21 @@ type file struct {
 	info    fileinfo
 	opener  LazyOpener
 	content []byte
+	spilled *spillFile   // content stored on disk instead of in content, see WithSpill
+	source  *mountSource // content not yet loaded from a mount, see openCached
+	lazy    bool         // content is provided by an external opener rather than held in content
+
+	compressed bool // content is gzip-compressed, see WithCompression
+
+	appendMu sync.Mutex // serialises appends, which read and then replace the content
 }
 
 type fileAccess struct {
 	file   *file
 	reader io.Reader
+	tree   *tree           // set for handles counted by OpenHandles
+	ctx    context.Context // aborts read latency, see OpenContext
+	closed bool
 }
 
 // LazyOpener provides an io.Reader that can be used to access the content of a file, whatever the actual storage medium.
@@ -27,7 +40,32 @@ type LazyOpener func() (io.Reader, error)
 
 const bufferSize = 0x100
 
-func (f *file) overwrite(data []byte, perm fs.FileMode) error {
+// overwrite replaces the content of the file with data, which has been prepared for storing as stored
+func (f *file) overwrite(data []byte, stored storage, perm fs.FileMode, modified time.Time) error {
+
+	f.Lock()
+	if !f.lazy {
+		// in-memory content is replaced in one step, so readers see either the old or the new content
+		previous := f.spilled
+		f.content = stored.content
+		f.spilled = stored.spilled
+		f.compressed = stored.compressed
+		f.uncache()
+		f.source = nil
+		f.opener = f.openMemory
+		f.info.size = stored.size
+		f.info.modified = modified
+		f.info.mode = perm
+		f.Unlock()
+		if previous != nil {
+			previous.release()
+		}
+		return nil
+	}
+	f.Unlock()
+
+	// lazy files are written through their opener, so the content is not kept
+	stored.release()
 
 	f.RLock()
 	if f.opener == nil {
@@ -43,19 +81,50 @@ func (f *file) overwrite(data []byte, perm fs.FileMode) error {
 
 	f.Lock()
 	f.info.size = int64(len(data))
-	f.info.modified = time.Now()
+	f.info.modified = modified
 	f.info.mode = perm
 	f.Unlock()
 
-	for len(data) > 0 {
+	// always write at least once, so that overwriting with empty data still truncates the content
+	for {
 		n, err := rw.Write(data)
 		if err != nil {
 			return err
 		}
 		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
 	}
+}
 
-	return nil
+// inMemory reports whether the content of the file is held in content
+func (f *file) inMemory() bool {
+	return !f.lazy && f.spilled == nil && f.source == nil && !f.compressed
+}
+
+// discard releases storage held by the file once it has been removed from the tree
+func (f *file) discard() {
+	f.Lock()
+	spilled := f.spilled
+	f.spilled = nil
+	f.uncache()
+	f.Unlock()
+	if spilled != nil {
+		spilled.release()
+	}
+}
+
+func (f *file) isSymlink() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.info.mode&fs.ModeSymlink != 0
+}
+
+func (f *file) linkTarget() string {
+	f.RLock()
+	defer f.RUnlock()
+	return string(f.content)
 }
 
 func (f *file) stat() fs.FileInfo {
@@ -82,6 +151,16 @@ func (f *fileAccess) Stat() (fs.FileInfo, error) {
 }
 
 func (f *fileAccess) Read(data []byte) (int, error) {
+	if f.ctx != nil {
+		if err := f.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	if f.tree != nil {
+		if err := f.tree.delayRead(f.ctx); err != nil {
+			return 0, err
+		}
+	}
 	r, err := func() (io.Reader, error) {
 		f.file.Lock()
 		defer f.file.Unlock()
@@ -103,6 +182,10 @@ func (f *fileAccess) Read(data []byte) (int, error) {
 func (f *fileAccess) Close() error {
 	f.file.Lock()
 	defer f.file.Unlock()
+	if f.tree != nil && !f.closed {
+		f.tree.closeHandle()
+	}
+	f.closed = true
 	if f.reader == nil {
 		return nil
 	}
@@ -135,9 +218,43 @@ func (f *fileAccess) Write(p []byte) (n int, err error) {
 	return w.Write(p)
 }
 
+// openMemory is the opener for files whose content is held in memory
+func (f *file) openMemory() (io.Reader, error) {
+	return &lazyAccess{
+		file: f,
+	}, nil
+}
+
+// clone returns a copy of the file which shares its (immutable) content
+func (f *file) clone() *file {
+	f.RLock()
+	defer f.RUnlock()
+	c := &file{
+		info:    f.info,
+		opener:  f.opener,
+		content: f.content,
+		spilled: f.spilled,
+		source:  f.source,
+		lazy:    f.lazy,
+
+		compressed: f.compressed,
+	}
+	if f.spilled != nil {
+		f.spilled.retain()
+	}
+	switch {
+	case f.source != nil:
+		c.opener = c.openCached
+	case !f.lazy:
+		c.opener = c.openMemory
+	}
+	return c
+}
+
 type lazyAccess struct {
 	file   *file
 	reader io.Reader
+	closer io.Closer
 	writer *bytes.Buffer
 }
 
@@ -145,22 +262,59 @@ func (l *lazyAccess) Read(data []byte) (int, error) {
 	l.file.RLock()
 	defer l.file.RUnlock()
 	if l.reader == nil {
-		l.reader = bytes.NewReader(l.file.content)
+		if l.file.spilled != nil {
+			r, err := l.file.spilled.open()
+			if err != nil {
+				return 0, err
+			}
+			l.reader, l.closer = r, r
+		} else if l.file.compressed {
+			r, err := gzip.NewReader(bytes.NewReader(l.file.content))
+			if err != nil {
+				return 0, err
+			}
+			l.reader = r
+		} else {
+			l.reader = bytes.NewReader(l.file.content)
+		}
 	}
 	return l.reader.Read(data)
 }
 
+func (l *lazyAccess) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
 func (l *lazyAccess) Write(data []byte) (int, error) {
 	l.file.Lock()
 	defer l.file.Unlock()
 	if l.writer == nil {
-		l.writer = bytes.NewBuffer(l.file.content)
-		l.writer.Reset()
+		// content is never modified in place, so slices of it handed out elsewhere (e.g. to snapshots) stay intact
+		l.writer = bytes.NewBuffer(make([]byte, 0, len(data)))
 	}
 	n, err := l.writer.Write(data)
 	if err != nil {
 		return 0, err
 	}
 	l.file.content = l.writer.Bytes()
+	l.file.compressed = false
+	l.file.info.size = int64(len(l.file.content))
+	if l.file.spilled != nil {
+		l.file.spilled.release()
+		l.file.spilled = nil
+	}
 	return n, nil
 }
+
+// readAll returns a copy of the content of the file, loading it via the opener if necessary
+func (f *file) readAll() ([]byte, error) {
+	access, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = access.Close() }()
+	return ioutil.ReadAll(access)
+}
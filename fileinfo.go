@@ -9,8 +9,11 @@ type fileinfo struct {
 	name     string
 	size     int64
 	modified time.Time
+	created  time.Time
 	mode     fs.FileMode
 	sys      interface{}
+
+	immutable bool // see SetImmutable
 }
 
 // Name is the base name of the file (without directory)
@@ -44,6 +47,16 @@ func (f fileinfo) ModTime() time.Time {
 	return f.modified
 }
 
+// Created is the creation (birth) time of the file. Unlike ModTime, it is not changed by later writes.
+func (f fileinfo) Created() time.Time {
+	return f.created
+}
+
+// Immutable reports whether the file has been marked immutable with SetImmutable.
+func (f fileinfo) Immutable() bool {
+	return f.immutable
+}
+
 // IsDir reports whether the entry describes a directory.
 func (f fileinfo) IsDir() bool {
 	return f.Mode().IsDir()
@@ -0,0 +1,59 @@
+package memoryfs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+)
+
+// Fingerprint returns a hex-encoded SHA-256 digest summarising the whole filesystem: the path, type and mode of
+// every entry, the content of every file and the target of every symbolic link. Modification times are not
+// included. Filesystems with the same entries always have the same fingerprint, however they were built, and any
+// change to an entry changes it. The content of lazy files is read; a file which cannot be read contributes the
+// error in place of its content.
+func (m *FS) Fingerprint() string {
+	h := sha256.New()
+	_ = m.dir.walk("", func(path string, d *dir, f *file) error {
+		writeField(h, []byte(toSlash(path, m.dir.sep())))
+		if d != nil {
+			info, _ := d.Stat()
+			writeField(h, []byte(strconv.FormatUint(uint64(info.Mode()), 8)))
+			return nil
+		}
+		writeField(h, []byte(strconv.FormatUint(uint64(f.stat().Mode()), 8)))
+		if f.isSymlink() {
+			writeField(h, []byte(f.linkTarget()))
+			return nil
+		}
+		content := sha256.New()
+		if err := copyContentTo(content, f); err != nil {
+			writeField(h, []byte(err.Error()))
+			return nil
+		}
+		writeField(h, content.Sum(nil))
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes data to h prefixed with its length, so that the boundaries between fields are unambiguous
+func writeField(h hash.Hash, data []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+	_, _ = h.Write(length[:])
+	_, _ = h.Write(data)
+}
+
+// copyContentTo writes the content of f to w
+func copyContentTo(w io.Writer, f *file) error {
+	access, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = access.Close() }()
+	_, err = io.Copy(w, access)
+	return err
+}
@@ -0,0 +1,59 @@
+package memoryfs
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fingerprint(t *testing.T) {
+	build := func() *FS {
+		memfs := New()
+		require.NoError(t, memfs.MkdirAll("a/b", 0o755))
+		require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("content"), 0o644))
+		require.NoError(t, memfs.WriteFile("a/other.txt", []byte("other"), 0o600))
+		require.NoError(t, memfs.Symlink("b/file.txt", "a/link"))
+		return memfs
+	}
+	first := build()
+
+	// built in a different order, with different times and a lazy file
+	second := New()
+	require.NoError(t, second.MkdirAll("a", 0o755))
+	require.NoError(t, second.WriteLazyFile("a/other.txt", func() (io.Reader, error) {
+		return strings.NewReader("other"), nil
+	}, 0o600))
+	require.NoError(t, second.Symlink("b/file.txt", "a/link"))
+	require.NoError(t, second.MkdirAll("a/b", 0o755))
+	require.NoError(t, second.WriteFileAt("a/b/file.txt", []byte("content"), 0o644, time.Unix(0, 0)))
+
+	fingerprint := first.Fingerprint()
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, second.Fingerprint())
+	assert.Equal(t, fingerprint, first.Fingerprint())
+
+	changes := []struct {
+		name   string
+		change func(memfs *FS) error
+	}{
+		{name: "content", change: func(memfs *FS) error { return memfs.WriteFile("a/b/file.txt", []byte("changed"), 0o644) }},
+		{name: "mode", change: func(memfs *FS) error { return memfs.WriteFile("a/other.txt", []byte("other"), 0o644) }},
+		{name: "new file", change: func(memfs *FS) error { return memfs.WriteFile("a/new.txt", nil, 0o644) }},
+		{name: "new directory", change: func(memfs *FS) error { return memfs.MkdirAll("a/empty", 0o755) }},
+		{name: "rename", change: func(memfs *FS) error { return memfs.Rename("a/other.txt", "a/renamed.txt") }},
+		{name: "remove", change: func(memfs *FS) error { return memfs.Remove("a/link") }},
+	}
+	for _, test := range changes {
+		t.Run(test.name, func(t *testing.T) {
+			memfs := build()
+			require.NoError(t, test.change(memfs))
+			assert.NotEqual(t, fingerprint, memfs.Fingerprint())
+		})
+	}
+
+	assert.NotEqual(t, New().Fingerprint(), fingerprint)
+}
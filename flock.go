@@ -0,0 +1,103 @@
+package memoryfs
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"syscall"
+)
+
+// flockTable holds the advisory locks of a tree, keyed by path from the root
+type flockTable struct {
+	sync.Mutex
+	locks map[string]*flockState
+}
+
+type flockState struct {
+	shared    int
+	exclusive bool
+	released  chan struct{} // closed whenever a lock is released, to wake waiters
+}
+
+// Lock acquires an advisory lock on the named file or directory, emulating flock: an exclusive lock excludes every
+// other lock on the path, while shared locks may be held by several callers at once. Lock blocks until the lock
+// can be acquired. The returned function releases the lock, and may safely be called more than once. Locks are
+// independent of all other operations, which they do not block.
+func (m *FS) Lock(name string, exclusive bool) (unlock func(), err error) {
+	return m.lock(context.Background(), "lock", name, exclusive, true)
+}
+
+// LockContext acquires an advisory lock as Lock does, returning the error of ctx if it is done before the lock
+// can be acquired.
+func (m *FS) LockContext(ctx context.Context, name string, exclusive bool) (unlock func(), err error) {
+	return m.lock(ctx, "lock", name, exclusive, true)
+}
+
+// TryLock acquires an advisory lock as Lock does, but fails with an error wrapping syscall.EWOULDBLOCK instead of
+// blocking if an incompatible lock is held.
+func (m *FS) TryLock(name string, exclusive bool) (unlock func(), err error) {
+	return m.lock(context.Background(), "trylock", name, exclusive, false)
+}
+
+func (m *FS) lock(ctx context.Context, op string, name string, exclusive bool, block bool) (func(), error) {
+	path, err := m.realpath(op, name, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.stat(op, path); err != nil {
+		return nil, err
+	}
+	key := m.dir.pathFromRoot(path)
+	table := &m.dir.tree.flocks
+
+	for {
+		table.Lock()
+		if table.locks == nil {
+			table.locks = map[string]*flockState{}
+		}
+		state, ok := table.locks[key]
+		if !ok {
+			state = &flockState{released: make(chan struct{})}
+			table.locks[key] = state
+		}
+		if !state.exclusive && (!exclusive || state.shared == 0) {
+			if exclusive {
+				state.exclusive = true
+			} else {
+				state.shared++
+			}
+			table.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() { table.release(key, exclusive) })
+			}, nil
+		}
+		released := state.released
+		table.Unlock()
+
+		if !block {
+			return nil, &fs.PathError{Op: op, Path: path, Err: syscall.EWOULDBLOCK}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, &fs.PathError{Op: op, Path: path, Err: ctx.Err()}
+		case <-released:
+		}
+	}
+}
+
+func (t *flockTable) release(key string, exclusive bool) {
+	t.Lock()
+	defer t.Unlock()
+	state := t.locks[key]
+	if exclusive {
+		state.exclusive = false
+	} else {
+		state.shared--
+	}
+	close(state.released)
+	state.released = make(chan struct{})
+	if !state.exclusive && state.shared == 0 {
+		delete(t.locks, key)
+	}
+}
@@ -0,0 +1,104 @@
+package memoryfs
+
+import (
+	"context"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LockExclusive(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.lock", nil, 0o644))
+
+	unlock, err := memfs.Lock("file.lock", true)
+	require.NoError(t, err)
+
+	_, err = memfs.TryLock("file.lock", true)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK)
+	_, err = memfs.TryLock("file.lock", false)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK)
+
+	unlock()
+	unlock()
+
+	unlock, err = memfs.TryLock("file.lock", true)
+	require.NoError(t, err)
+	unlock()
+}
+
+func Test_LockShared(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+
+	first, err := memfs.Lock("dir", false)
+	require.NoError(t, err)
+	second, err := memfs.TryLock("dir", false)
+	require.NoError(t, err)
+
+	_, err = memfs.TryLock("dir", true)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK)
+
+	first()
+	_, err = memfs.TryLock("dir", true)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK)
+
+	second()
+	unlock, err := memfs.TryLock("dir", true)
+	require.NoError(t, err)
+	unlock()
+}
+
+func Test_LockBlocks(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.lock", nil, 0o644))
+
+	unlock, err := memfs.Lock("file.lock", true)
+	require.NoError(t, err)
+
+	acquired := make(chan func())
+	go func() {
+		unlock, err := memfs.Lock("file.lock", true)
+		if err == nil {
+			acquired <- unlock
+		}
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired while held exclusively")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case unlock := <-acquired:
+		unlock()
+	case <-time.After(time.Second):
+		t.Fatal("lock not acquired after release")
+	}
+}
+
+func Test_LockContext(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.lock", nil, 0o644))
+
+	unlock, err := memfs.Lock("file.lock", false)
+	require.NoError(t, err)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = memfs.LockContext(ctx, "file.lock", true)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_LockMissing(t *testing.T) {
+	memfs := New()
+	_, err := memfs.Lock("missing", true)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
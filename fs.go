@@ -1,10 +1,17 @@
 package memoryfs
 
 import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"io/ioutil"
+	"path"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,15 +20,30 @@ type FS struct {
 	dir *dir
 }
 
-// New creates a new filesystem
-func New() *FS {
+// New creates a new filesystem, configured by the given options
+func New(opts ...Option) *FS {
+	o := &options{
+		rootName:  ".",
+		rootMode:  0o0700,
+		gzipLevel: gzip.DefaultCompression,
+		separator: separator,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	now := time.Now()
 	return &FS{
 		dir: &dir{
+			tree: &tree{
+				opts: o,
+				lru:  lruCache{max: o.lruBytes},
+			},
 			info: fileinfo{
-				name:     ".",
+				name:     o.rootName,
 				size:     0x100,
-				modified: time.Now(),
-				mode:     0o0700 | fs.ModeDir,
+				modified: now,
+				created:  now,
+				mode:     o.rootMode | fs.ModeDir,
 			},
 			dirs:  map[string]*dir{},
 			files: map[string]*file{},
@@ -50,32 +72,273 @@ func CloneFS(base fs.FS) *FS {
 	return newFS
 }
 
+// FromMap creates a new filesystem containing a file for each path in files, holding the corresponding content.
+// Parent directories are created as required. Files are created with permissions 0644 and directories with 0755.
+// An error is returned if a path is empty or would escape the root, or if paths conflict with each other.
+func FromMap(files map[string]string) (*FS, error) {
+	data := make(map[string][]byte, len(files))
+	for path, content := range files {
+		data[path] = []byte(content)
+	}
+	return FromBytesMap(data)
+}
+
+// FromBytesMap creates a new filesystem from a map of paths to content, as FromMap does.
+func FromBytesMap(files map[string][]byte) (*FS, error) {
+	m := New()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	created := make(map[string]string, len(paths))
+	for _, name := range paths {
+		path, err := secureCleanse(name, m.dir.sep())
+		if err == nil && path == "" {
+			err = fs.ErrInvalid
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "frommap", Path: name, Err: err}
+		}
+		if previous, ok := created[path]; ok {
+			return nil, fmt.Errorf("paths '%s' and '%s' refer to the same file: %w", previous, name, fs.ErrExist)
+		}
+		created[path] = name
+		if err := m.WriteFileDeep(path, files[name], defaultFilePerm); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ToMap returns a map of the path of every regular file in the filesystem to a copy of its content, the reverse of
+// FromBytesMap. Directories and symbolic links are not included, nor are files whose content cannot be read.
+func (m *FS) ToMap() map[string][]byte {
+	files := map[string][]byte{}
+	_ = m.dir.walk("", func(path string, _ *dir, f *file) error {
+		if f == nil || !f.stat().Mode().IsRegular() || m.checkAccess("open", path) != nil {
+			return nil
+		}
+		if data, err := f.readAll(); err == nil {
+			files[path] = data
+		}
+		return nil
+	})
+	return files
+}
+
 // Stat returns a FileInfo describing the file.
+// Symbolic links are followed.
 func (m *FS) Stat(name string) (fs.FileInfo, error) {
-	name = cleanse(name)
-	if f, err := m.dir.getFile(name); err == nil {
+	path, err := m.realpath("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return m.stat("stat", path)
+}
+
+func (m *FS) stat(op string, path string) (fs.FileInfo, error) {
+	if f, err := m.dir.getFile(path); err == nil {
 		return f.stat(), nil
 	}
-	if f, err := m.dir.getDir(name); err == nil {
-		return f.Stat()
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
 	}
-	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	return d.Stat()
 }
 
 // ReadDir reads the named directory
 // and returns a list of directory entries sorted by filename.
 func (m *FS) ReadDir(name string) ([]fs.DirEntry, error) {
-	return m.dir.ReadDir(cleanse(name))
+	path, err := m.realpath("readdir", name, true)
+	if err != nil {
+		m.recordAccess("readdir", m.Clean(name))
+		return nil, err
+	}
+	m.recordAccess("readdir", path)
+	if err := m.checkAccess("readdir", path); err != nil {
+		return nil, err
+	}
+	return m.dir.ReadDir(path)
+}
+
+// ReadDirFilter reads the named directory as ReadDir does, but only returns the entries for which keep returns
+// true. Entries are filtered as the directory is enumerated, so rejected entries are never collected. The
+// directory is locked while keep is called, so keep must not call back into the filesystem.
+func (m *FS) ReadDirFilter(name string, keep func(fs.DirEntry) bool) ([]fs.DirEntry, error) {
+	path, err := m.realpath("readdir", name, true)
+	if err != nil {
+		m.recordAccess("readdir", m.Clean(name))
+		return nil, err
+	}
+	m.recordAccess("readdir", path)
+	if err := m.checkAccess("readdir", path); err != nil {
+		return nil, err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: path, Err: err}
+	}
+	return d.readDirFilter(keep), nil
+}
+
+// ReadDirDirs returns the sorted names of the immediate subdirectories of the named directory.
+func (m *FS) ReadDirDirs(name string) ([]string, error) {
+	d, _, err := m.walkRoot("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.visibleNames(d.dirNames()), nil
+}
+
+// ReadDirFiles returns the sorted names of the files contained directly within the named directory.
+func (m *FS) ReadDirFiles(name string) ([]string, error) {
+	d, _, err := m.walkRoot("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.visibleNames(d.fileNames()), nil
+}
+
+// ReadDirPaths returns the sorted paths, relative to the root of the filesystem, of the immediate children of the
+// named directory.
+func (m *FS) ReadDirPaths(name string) ([]string, error) {
+	_, path, err := m.walkRoot("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := m.dir.ReadDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: path, Err: err}
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, joinPath(path, entry.Name(), m.dir.sep()))
+	}
+	return paths, nil
+}
+
+// ReadDirDepth returns the paths of the descendants of the named directory up to maxDepth levels below it, so a
+// maxDepth of 1 returns the same paths as ReadDirPaths. A negative maxDepth returns every descendant. The paths
+// are relative to the root of the filesystem and sorted.
+func (m *FS) ReadDirDepth(name string, maxDepth int) ([]string, error) {
+	d, path, err := m.walkRoot("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	sep := m.dir.sep()
+	paths := []string{}
+	_ = d.walk("", func(child string, sub *dir, _ *file) error {
+		if child == "" {
+			if maxDepth == 0 {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if _, name := splitPath(child, sep); d.hidden(name) {
+			if sub != nil {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		paths = append(paths, joinPath(path, child, sep))
+		if sub != nil && maxDepth >= 0 && strings.Count(child, sep)+1 >= maxDepth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Newest returns the path and info of the most recently modified file directly within the named directory.
+// If several files share the latest modification time, the first by name is returned. An error wrapping
+// fs.ErrNotExist is returned if the directory is missing or contains no files.
+func (m *FS) Newest(dir string) (string, fs.FileInfo, error) {
+	d, path, err := m.walkRoot("newest", dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var newest fs.FileInfo
+	for _, name := range d.fileNames() {
+		f, err := d.getFile(name)
+		if err != nil || f.isSymlink() {
+			continue
+		}
+		info := f.stat()
+		if newest == nil || info.ModTime().After(newest.ModTime()) {
+			newest = info
+		}
+	}
+	if newest == nil {
+		return "", nil, &fs.PathError{Op: "newest", Path: path, Err: fs.ErrNotExist}
+	}
+	return joinPath(path, newest.Name(), m.dir.sep()), newest, nil
 }
 
 // Open opens the named file for reading.
 func (m *FS) Open(name string) (fs.File, error) {
-	return m.dir.Open(cleanse(name))
+	path, err := m.realpath("open", name, true)
+	if err != nil {
+		m.recordAccess("open", m.Clean(name))
+		return nil, err
+	}
+	m.recordAccess("open", path)
+	if err := m.checkAccess("open", path); err != nil {
+		return nil, err
+	}
+	return m.dir.Open(path)
 }
 
 // WriteFile writes the specified bytes to the named file. If the file exists, it will be overwritten.
-func (m *FS) WriteFile(path string, data []byte, perm fs.FileMode) error {
-	return m.dir.WriteFile(cleanse(path), data, perm)
+func (m *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return m.WriteFileAt(name, data, perm, time.Now())
+}
+
+// WriteFileAt writes the named file as WriteFile does, recording mtime as its modification time rather than the
+// current time. If the file is created, the modification time of the parent directory is set to the current time
+// unless the filesystem was created WithStaticDirTimes.
+func (m *FS) WriteFileAt(name string, data []byte, perm fs.FileMode, mtime time.Time) error {
+	path, err := m.realpath("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNameLen("write", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("write", path); err != nil {
+		return err
+	}
+	if err := m.writeFileAt(path, data, perm, mtime); err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "write", Path: path, Data: data, Mode: perm, ModTime: mtime})
+	return nil
+}
+
+// writeFileAt writes the file at the resolved path once the access checker, if any, permits it
+func (m *FS) writeFileAt(path string, data []byte, perm fs.FileMode, mtime time.Time) error {
+	if err := m.checkAccess("write", path); err != nil {
+		return err
+	}
+	return m.dir.writeFileAt(path, data, perm, mtime)
+}
+
+// WriteReader writes the content read from r until io.EOF to the named file, as WriteFile does.
+func (m *FS) WriteReader(name string, r io.Reader, perm fs.FileMode) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
+	return m.WriteFile(name, data, perm)
+}
+
+// WriteReaderHash writes the content read from r to the named file as WriteReader does, also writing it to h as
+// it is read, so that h.Sum gives the digest of the content once WriteReaderHash returns.
+func (m *FS) WriteReaderHash(name string, r io.Reader, perm fs.FileMode, h hash.Hash) error {
+	return m.WriteReader(name, io.TeeReader(r, h), perm)
 }
 
 // MkdirAll creates a directory named path,
@@ -85,8 +348,22 @@ func (m *FS) WriteFile(path string, data []byte, perm fs.FileMode) error {
 // directories that MkdirAll creates.
 // If path is already a directory, MkdirAll does nothing
 // and returns nil.
-func (m *FS) MkdirAll(path string, perm fs.FileMode) error {
-	return m.dir.MkdirAll(cleanse(path), perm)
+func (m *FS) MkdirAll(name string, perm fs.FileMode) error {
+	path, err := m.realpath("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNameLen("mkdir", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("mkdir", path); err != nil {
+		return err
+	}
+	if err := m.dir.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "mkdir", Path: path, Mode: perm})
+	return nil
 }
 
 // ReadFile reads the named file and returns its contents.
@@ -97,7 +374,7 @@ func (m *FS) MkdirAll(path string, perm fs.FileMode) error {
 // The caller is permitted to modify the returned byte slice.
 // This method should return a copy of the underlying data.
 func (m *FS) ReadFile(name string) ([]byte, error) {
-	f, err := m.dir.Open(cleanse(name))
+	f, err := m.Open(name)
 	if err != nil {
 		return nil, err
 	}
@@ -107,10 +384,39 @@ func (m *FS) ReadFile(name string) ([]byte, error) {
 
 // Sub returns an FS corresponding to the subtree rooted at dir.
 func (m *FS) Sub(dir string) (fs.FS, error) {
-	d, err := m.dir.getDir(cleanse(dir))
+	view, err := m.view("sub", dir)
 	if err != nil {
 		return nil, err
 	}
+	return view, nil
+}
+
+// StripPrefix returns an FS view of the tree where prefix is removed from every path, i.e. Open("x") on the view
+// opens prefix/x on m, as Sub does. The prefix must be an existing directory: an error wrapping fs.ErrInvalid is
+// returned if it is, or passes through, a file. The returned value is an *FS, so writes made through the view are
+// applied to m.
+func (m *FS) StripPrefix(name string) (fs.FS, error) {
+	view, err := m.view("stripprefix", name)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) && pathErr.Err == ErrNotDir {
+			pathErr.Err = fs.ErrInvalid
+		}
+		return nil, err
+	}
+	return view, nil
+}
+
+// view returns an *FS rooted at the named directory which shares its tree with m
+func (m *FS) view(op string, name string) (*FS, error) {
+	path, err := m.realpath(op, name, true)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: path, Err: err}
+	}
 	return &FS{
 		dir: d,
 	}, nil
@@ -125,35 +431,134 @@ func (m *FS) Sub(dir string) (fs.FS, error) {
 // The only possible returned error is ErrBadPattern, when pattern
 // is malformed.
 func (m *FS) Glob(pattern string) ([]string, error) {
-	pattern = strings.ReplaceAll(pattern, "/", separator)
-	return m.dir.glob(pattern)
+	pattern = fromSlash(pattern, m.dir.sep())
+	matches, err := m.dir.glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return m.listable(matches), nil
+}
+
+// RemoveGlob removes every file and symbolic link matching pattern, using the same syntax as Glob, and returns the
+// number of entries removed. Matching directories are removed along with their contents if recursive is true,
+// and are otherwise left alone. A malformed pattern returns path.ErrBadPattern.
+func (m *FS) RemoveGlob(pattern string, recursive bool) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+	matches, err := m.Glob(pattern)
+	if err != nil {
+		return 0, path.ErrBadPattern
+	}
+	var removed int
+	for _, match := range matches {
+		info, err := m.Lstat(match)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			if !recursive {
+				continue
+			}
+			err = m.RemoveAll(match)
+		} else {
+			err = m.Remove(match)
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
 }
 
 // WriteLazyFile creates (or overwrites) the named file.
 // The contents of the file are not set at this time, but are read on-demand later using the provided LazyOpener.
-func (m *FS) WriteLazyFile(path string, opener LazyOpener, perm fs.FileMode) error {
-	return m.dir.WriteLazyFile(cleanse(path), opener, perm)
+func (m *FS) WriteLazyFile(name string, opener LazyOpener, perm fs.FileMode) error {
+	path, err := m.realpath("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNameLen("write", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("write", path); err != nil {
+		return err
+	}
+	if err := m.checkAccess("write", path); err != nil {
+		return err
+	}
+	return m.dir.WriteLazyFile(path, opener, perm)
 }
 
-// Remove deletes a file or directory from the filesystem
-func (m *FS) Remove(path string) error {
-	return m.dir.Remove(cleanse(path))
+// Remove deletes a file or directory from the filesystem. If path is a symbolic link, the link itself is removed.
+// If the filesystem was created WithTrash, the entry is moved to the trash instead.
+func (m *FS) Remove(name string) error {
+	path, err := m.realpath("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if m.dir.tree.opts.trash {
+		err = m.moveToTrash(path, false)
+	} else {
+		err = m.dir.Remove(path)
+	}
+	if err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "remove", Path: path})
+	return nil
+}
+
+// RemoveAll deletes a file or directory and any children if present from the filesystem. If the filesystem was
+// created WithTrash, the entry is moved to the trash instead.
+func (m *FS) RemoveAll(name string) error {
+	path, err := m.realpath("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if m.dir.tree.opts.trash {
+		err = m.moveToTrash(path, true)
+	} else {
+		err = m.dir.RemoveAll(path)
+	}
+	if err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "removeall", Path: path})
+	return nil
 }
 
-// RemoveAll deletes a file or directory and any children if present from the filesystem
-func (m *FS) RemoveAll(path string) error {
-	return m.dir.RemoveAll(cleanse(path))
+// DirSize returns the sum of the sizes of all files contained within the named directory and its subdirectories.
+// The result is cached and only recomputed after a write within the subtree. Lazy files do not contribute to the size.
+func (m *FS) DirSize(name string) (int64, error) {
+	path, err := m.realpath("dirsize", name, true)
+	if err != nil {
+		return 0, err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return 0, &fs.PathError{Op: "dirsize", Path: path, Err: err}
+	}
+	return d.size(), nil
 }
 
 // SetModified set modified time to file or directory
 func (m *FS) SetModified(name string, modified time.Time) error {
-	name = cleanse(name)
+	name, err := m.realpath("set modified", name, true)
+	if err != nil {
+		return err
+	}
 	if f, err := m.dir.getFile(name); err == nil {
+		f.Lock()
 		f.info.modified = modified
+		f.Unlock()
 		return nil
 	}
-	if f, err := m.dir.getDir(name); err == nil {
-		f.info.modified = modified
+	if d, err := m.dir.getDir(name); err == nil {
+		d.Lock()
+		d.info.modified = modified
+		d.Unlock()
 		return nil
 	}
 	return &fs.PathError{Op: "set modified", Path: name, Err: fs.ErrNotExist}
@@ -161,14 +566,48 @@ func (m *FS) SetModified(name string, modified time.Time) error {
 
 // SetSys set underlying data source to file or directory
 func (m *FS) SetSys(name string, sys interface{}) error {
-	name = cleanse(name)
+	name, err := m.realpath("set sys", name, true)
+	if err != nil {
+		return err
+	}
 	if f, err := m.dir.getFile(name); err == nil {
+		f.Lock()
 		f.info.sys = sys
+		f.Unlock()
 		return nil
 	}
-	if f, err := m.dir.getDir(name); err == nil {
-		f.info.sys = sys
+	if d, err := m.dir.getDir(name); err == nil {
+		d.Lock()
+		d.info.sys = sys
+		d.Unlock()
 		return nil
 	}
 	return &fs.PathError{Op: "set sys", Path: name, Err: fs.ErrNotExist}
 }
+
+// Chbtime set creation (birth) time to file or directory
+func (m *FS) Chbtime(name string, created time.Time) error {
+	name, err := m.realpath("chbtime", name, true)
+	if err != nil {
+		return err
+	}
+	if f, err := m.dir.getFile(name); err == nil {
+		f.Lock()
+		f.info.created = created
+		f.Unlock()
+		return nil
+	}
+	if d, err := m.dir.getDir(name); err == nil {
+		d.Lock()
+		d.info.created = created
+		d.Unlock()
+		return nil
+	}
+	return &fs.PathError{Op: "chbtime", Path: name, Err: fs.ErrNotExist}
+}
+
+// OpenHandles returns the number of file handles returned by Open, or its variants, which have not yet been closed.
+// Directory handles hold no resources and are not counted. This is intended for detecting leaked handles in tests.
+func (m *FS) OpenHandles() int {
+	return int(atomic.LoadInt64(&m.dir.tree.handles))
+}
@@ -2,13 +2,18 @@ package memoryfs
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"io/ioutil"
+	"path"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -67,7 +72,15 @@ func Test_AllOperations(t *testing.T) {
 		defer func() { _ = f.Close() }()
 		require.NotNil(t, f)
 		_, err = f.Read([]byte{})
-		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrIsDir)
+	})
+
+	t.Run("Read directory", func(t *testing.T) {
+		_, err := memfs.ReadFile("files")
+		assert.ErrorIs(t, err, ErrIsDir)
+		_, err = memfs.Bytes("files")
+		assert.ErrorIs(t, err, ErrIsDir)
+		assert.ErrorIs(t, err, syscall.EISDIR)
 	})
 
 	t.Run("Open file in dir", func(t *testing.T) {
@@ -507,6 +520,961 @@ func Test_CloneFS(t *testing.T) {
 
 }
 
+func Test_CreatedTimePreservedAcrossWrites(t *testing.T) {
+	type createdInfo interface {
+		Created() time.Time
+	}
+
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("test.txt", []byte("hello"), 0o644))
+
+	stat, err := memfs.Stat("test.txt")
+	require.NoError(t, err)
+	created := stat.(createdInfo).Created()
+	assert.False(t, created.IsZero())
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, memfs.WriteFile("test.txt", []byte("hello world"), 0o644))
+
+	stat, err = memfs.Stat("test.txt")
+	require.NoError(t, err)
+	assert.Equal(t, created, stat.(createdInfo).Created())
+	assert.True(t, stat.ModTime().After(created))
+
+	birth := time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)
+	require.NoError(t, memfs.Chbtime("test.txt", birth))
+	stat, err = memfs.Stat("test.txt")
+	require.NoError(t, err)
+	assert.Equal(t, birth, stat.(createdInfo).Created())
+
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.Chbtime("a/b", birth))
+	stat, err = memfs.Stat("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, birth, stat.(createdInfo).Created())
+
+	assert.Error(t, memfs.Chbtime("missing.txt", birth))
+}
+
+func Test_ChbtimeConcurrentStat(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("test.txt", nil, 0o644))
+
+	birth := time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)
+	var wg sync.WaitGroup
+	for _, name := range []string{"test.txt", "dir"} {
+		wg.Add(2)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				assert.NoError(t, memfs.Chbtime(name, birth.Add(time.Duration(i)*time.Second)))
+				assert.NoError(t, memfs.SetSys(name, i))
+			}
+		}(name)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				info, err := memfs.Stat(name)
+				assert.NoError(t, err)
+				_ = info.Sys()
+			}
+		}(name)
+	}
+	wg.Wait()
+}
+
+func Test_DirSize(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b/c", 0o700))
+	require.NoError(t, memfs.WriteFile("a/one.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/b/c/two.txt", []byte("world!"), 0o644))
+	require.NoError(t, memfs.WriteFile("outside.txt", []byte("ignored"), 0o644))
+
+	size, err := memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), size)
+
+	size, err = memfs.DirSize("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), size)
+
+	require.NoError(t, memfs.WriteFile("a/b/c/two.txt", []byte("hi"), 0o644))
+	size, err = memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), size)
+
+	sub, err := memfs.Sub("a/b")
+	require.NoError(t, err)
+	require.NoError(t, sub.(*FS).WriteFile("three.txt", []byte("abc"), 0o644))
+	size, err = memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+
+	require.NoError(t, memfs.Remove("a/one.txt"))
+	size, err = memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), size)
+
+	size, err = memfs.DirSize(".")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), size)
+
+	_, err = memfs.DirSize("missing")
+	require.Error(t, err)
+}
+
+func Test_StripPrefix(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("layers/base", 0o700))
+	require.NoError(t, memfs.WriteFile("layers/base/config.json", []byte("{}"), 0o644))
+	require.NoError(t, memfs.WriteFile("layers/file.txt", []byte("not a dir"), 0o644))
+
+	view, err := memfs.StripPrefix("/layers/base")
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(view, "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	require.NoError(t, view.(*FS).WriteFile("new.txt", []byte("written through"), 0o644))
+	data, err = memfs.ReadFile("layers/base/new.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "written through", string(data))
+
+	_, err = memfs.StripPrefix("layers/file.txt")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	_, err = memfs.StripPrefix("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Sub shares the resolution, but reports files as not being directories
+	_, err = memfs.Sub("layers/file.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+	_, err = memfs.Sub("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ReadDirDirsAndFiles(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("root/b", 0o700))
+	require.NoError(t, memfs.MkdirAll("root/a/nested", 0o700))
+	require.NoError(t, memfs.WriteFile("root/z.txt", []byte("z"), 0o644))
+	require.NoError(t, memfs.WriteFile("root/y.txt", []byte("y"), 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/nested/deep.txt", []byte("deep"), 0o644))
+
+	dirs, err := memfs.ReadDirDirs("root")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, dirs)
+
+	files, err := memfs.ReadDirFiles("root")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"y.txt", "z.txt"}, files)
+
+	files, err = memfs.ReadDirFiles("root/b")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	_, err = memfs.ReadDirDirs("root/z.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	_, err = memfs.ReadDirFiles("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_EmptyFile(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("empty", []byte{}, 0o644))
+
+	info, err := memfs.Stat("empty")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+	assert.False(t, info.IsDir())
+
+	entries, err := memfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "empty", entries[0].Name())
+	assert.False(t, entries[0].IsDir())
+	info, err = entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	data, err := memfs.ReadFile("empty")
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	require.NoError(t, memfs.WriteFile("truncated", []byte("hello world"), 0o644))
+	require.NoError(t, memfs.WriteFile("truncated", nil, 0o644))
+
+	info, err = memfs.Stat("truncated")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	data, err = memfs.ReadFile("truncated")
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func Test_ReadDirPaths(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b/sub", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.WriteFile("root.txt", []byte("hello"), 0o644))
+
+	paths, err := memfs.ReadDirPaths("/a/b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		strings.ReplaceAll("a/b/file.txt", "/", separator),
+		strings.ReplaceAll("a/b/sub", "/", separator),
+	}, paths)
+
+	paths, err = memfs.ReadDirPaths(".")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "root.txt"}, paths)
+
+	_, err = memfs.ReadDirPaths("root.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+}
+
+func Test_RemoveGlob(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("build/gen.d", 0o700))
+	require.NoError(t, memfs.WriteFile("build/a.gen", []byte("a"), 0o644))
+	require.NoError(t, memfs.WriteFile("build/b.gen", []byte("b"), 0o644))
+	require.NoError(t, memfs.WriteFile("build/keep.txt", []byte("keep"), 0o644))
+	require.NoError(t, memfs.WriteFile("build/gen.d/nested.gen", []byte("nested"), 0o644))
+
+	removed, err := memfs.RemoveGlob("build/*.gen", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	files, err := memfs.ReadDirFiles("build")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"keep.txt"}, files)
+
+	removed, err = memfs.RemoveGlob("build/gen.*", false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	_, err = memfs.Stat("build/gen.d/nested.gen")
+	require.NoError(t, err)
+
+	removed, err = memfs.RemoveGlob("build/gen.*", true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, err = memfs.Stat("build/gen.d")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = memfs.RemoveGlob("build/[", true)
+	assert.ErrorIs(t, err, path.ErrBadPattern)
+}
+
+func Test_ConfiguredRoot(t *testing.T) {
+	memfs := New(WithRootName("/"), WithRootMode(0o755))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("hello"), 0o644))
+
+	for _, name := range []string{".", "/", ""} {
+		info, err := memfs.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, "/", info.Name())
+		assert.Equal(t, fs.FileMode(0o755)|fs.ModeDir, info.Mode())
+
+		entries, err := memfs.ReadDir(name)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "file.txt", entries[0].Name())
+	}
+
+	memfs = New(WithRootName("rootfs"))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("hello"), 0o644))
+	info, err := memfs.Stat("rootfs")
+	require.NoError(t, err)
+	assert.Equal(t, "rootfs", info.Name())
+	assert.True(t, info.IsDir())
+	assert.Equal(t, fs.FileMode(0o700)|fs.ModeDir, info.Mode())
+
+	entries, err := memfs.ReadDir("rootfs")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func Test_Newest(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("logs/archive", 0o700))
+	require.NoError(t, memfs.MkdirAll("empty", 0o700))
+	for name, hour := range map[string]int{"a.log": 1, "c.log": 3, "b.log": 3, "d.log": 2} {
+		require.NoError(t, memfs.WriteFile("logs/"+name, []byte(name), 0o644))
+		require.NoError(t, memfs.SetModified("logs/"+name, time.Date(2020, 1, 1, hour, 0, 0, 0, time.UTC)))
+	}
+	require.NoError(t, memfs.SetModified("logs/archive", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	path, info, err := memfs.Newest("logs")
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll("logs/b.log", "/", separator), path)
+	assert.Equal(t, "b.log", info.Name())
+
+	_, _, err = memfs.Newest("empty")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, _, err = memfs.Newest("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_DirEntryType(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o755))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.Symlink("file.txt", "link"))
+
+	entries, err := memfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	types := map[string]fs.FileMode{}
+	for _, entry := range entries {
+		types[entry.Name()] = entry.Type()
+		info, err := entry.Info()
+		require.NoError(t, err)
+		assert.Equal(t, info.Mode().Type(), entry.Type())
+	}
+	assert.Equal(t, map[string]fs.FileMode{
+		"dir":      fs.ModeDir,
+		"file.txt": 0,
+		"link":     fs.ModeSymlink,
+	}, types)
+}
+
+func Test_CleanAndRel(t *testing.T) {
+	memfs := New()
+
+	for input, expected := range map[string]string{
+		"":              "",
+		".":             "",
+		"/":             "",
+		"./a/b/":        "a/b",
+		"/a//b/../c":    "a/c",
+		"a/./b/./c.txt": "a/b/c.txt",
+	} {
+		cleaned := memfs.Clean(input)
+		assert.Equal(t, strings.ReplaceAll(expected, "/", separator), cleaned, input)
+		assert.Equal(t, cleaned, memfs.Clean(cleaned), "Clean should be idempotent for %q", input)
+	}
+
+	for _, test := range []struct {
+		base     string
+		target   string
+		expected string
+	}{
+		{base: "/", target: "/a/b", expected: "a/b"},
+		{base: "a", target: "a/b/c", expected: "b/c"},
+		{base: "a/b", target: "a/c", expected: "../c"},
+		{base: "./a/", target: "a", expected: "."},
+	} {
+		rel, err := memfs.Rel(test.base, test.target)
+		require.NoError(t, err)
+		assert.Equal(t, strings.ReplaceAll(test.expected, "/", separator), rel)
+	}
+}
+
+func Test_MaxFiles(t *testing.T) {
+	memfs := New(WithMaxFiles(3))
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("one.txt", []byte("1"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/two.txt", []byte("2"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/b/three.txt", []byte("3"), 0o644))
+
+	err := memfs.WriteFile("a/b/four.txt", []byte("4"), 0o644)
+	assert.ErrorIs(t, err, ErrTooManyFiles)
+	_, err = memfs.Stat("a/b/four.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	countFiles := func() int {
+		var count int
+		require.NoError(t, fs.WalkDir(memfs, ".", func(_ string, d fs.DirEntry, err error) error {
+			require.NoError(t, err)
+			if d.Type().IsRegular() {
+				count++
+			}
+			return nil
+		}))
+		return count
+	}
+	assert.Equal(t, 3, countFiles())
+
+	// overwriting an existing file does not consume budget
+	require.NoError(t, memfs.WriteFile("one.txt", []byte("updated"), 0o644))
+
+	require.NoError(t, memfs.Remove("one.txt"))
+	require.NoError(t, memfs.WriteFile("a/b/four.txt", []byte("4"), 0o644))
+	assert.ErrorIs(t, memfs.WriteFile("five.txt", []byte("5"), 0o644), ErrTooManyFiles)
+
+	require.NoError(t, memfs.RemoveAll("a"))
+	assert.Equal(t, 0, countFiles())
+	for i := 0; i < 3; i++ {
+		require.NoError(t, memfs.WriteFile(fmt.Sprintf("new_%d.txt", i), nil, 0o644))
+	}
+	assert.ErrorIs(t, memfs.WriteFile("new_3.txt", nil, 0o644), ErrTooManyFiles)
+	assert.Equal(t, 3, countFiles())
+}
+
+func Test_MaxEntries(t *testing.T) {
+	memfs := New(WithMaxEntries(5))
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("a/b", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/one.txt", "/", separator), []byte("1"), 0o644))
+	require.NoError(t, memfs.Symlink("a", "link"))
+
+	// a chain of directories is created in full or not at all
+	err := memfs.MkdirAll(strings.ReplaceAll("a/b/c/d", "/", separator), 0o700)
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+	_, err = memfs.Stat(strings.ReplaceAll("a/b/c", "/", separator))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, memfs.WriteFile("two.txt", []byte("2"), 0o644))
+	assert.ErrorIs(t, memfs.WriteFile("three.txt", []byte("3"), 0o644), ErrTooManyEntries)
+	assert.ErrorIs(t, memfs.MkdirAll("c", 0o700), ErrTooManyEntries)
+	assert.ErrorIs(t, memfs.Symlink("two.txt", "other"), ErrTooManyEntries)
+	assert.ErrorIs(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		return strings.NewReader("lazy"), nil
+	}, 0o644), ErrTooManyEntries)
+	_, err = memfs.Stat("three.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// overwriting and renaming over an existing entry do not consume budget
+	require.NoError(t, memfs.WriteFile("two.txt", []byte("updated"), 0o644))
+	require.NoError(t, memfs.Rename("two.txt", strings.ReplaceAll("a/one.txt", "/", separator)))
+	require.NoError(t, memfs.WriteFile("three.txt", []byte("3"), 0o644))
+	require.NoError(t, memfs.Verify())
+
+	require.NoError(t, memfs.RemoveAll("a"))
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("x/y/z", "/", separator), 0o700))
+	assert.ErrorIs(t, memfs.WriteFile("four.txt", nil, 0o644), ErrTooManyEntries)
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_MaxEntriesTrash(t *testing.T) {
+	memfs := New(WithMaxEntries(3), WithTrash())
+	require.NoError(t, memfs.MkdirAll("a", 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/file.txt", "/", separator), nil, 0o644))
+	require.NoError(t, memfs.WriteFile("other.txt", nil, 0o644))
+
+	require.NoError(t, memfs.RemoveAll("a"))
+	require.NoError(t, memfs.Verify())
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("b/c", "/", separator), 0o700))
+	assert.ErrorIs(t, memfs.WriteFile("more.txt", nil, 0o644), ErrTooManyEntries)
+	require.NoError(t, memfs.RemoveAll("b"))
+
+	require.NoError(t, memfs.Restore("a"))
+	require.NoError(t, memfs.Verify())
+	assert.ErrorIs(t, memfs.WriteFile("more.txt", nil, 0o644), ErrTooManyEntries)
+}
+
+func Test_MaxEntriesLeavesNothingBehind(t *testing.T) {
+	memfs := New(WithMaxEntries(3))
+
+	// the directories fit within the limit but the file does not
+	err := memfs.WriteFileDeep(strings.ReplaceAll("a/b/c/file.txt", "/", separator), []byte("data"), 0o644)
+	var pathErr *fs.PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+	_, err = memfs.Stat("a")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, memfs.Verify())
+
+	// existing directories are kept
+	require.NoError(t, memfs.MkdirAll("a", 0o700))
+	err = memfs.WriteFileDeep(strings.ReplaceAll("a/b/c/file.txt", "/", separator), []byte("data"), 0o644)
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+	_, err = memfs.Stat("a")
+	assert.NoError(t, err)
+	_, err = memfs.Stat(strings.ReplaceAll("a/b", "/", separator))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, memfs.Verify())
+
+	// a later move which does not fit undoes the earlier moves and the directories created for them
+	memfs = New(WithMaxEntries(4))
+	require.NoError(t, memfs.WriteFileDeep(strings.ReplaceAll("a/x.txt", "/", separator), []byte("x"), 0o644))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/y.txt", "/", separator), []byte("y"), 0o644))
+	err = memfs.RenameFunc("a", func(path string) string {
+		if strings.HasSuffix(path, "x.txt") {
+			return strings.ReplaceAll("b/x.txt", "/", separator)
+		}
+		return strings.ReplaceAll("c/d/y.txt", "/", separator)
+	})
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+	_, err = memfs.Stat("b")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.Stat("c")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	for _, name := range []string{"a/x.txt", "a/y.txt"} {
+		_, err = memfs.Stat(strings.ReplaceAll(name, "/", separator))
+		assert.NoError(t, err, name)
+	}
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_NoClobber(t *testing.T) {
+	memfs := New(WithNoClobber())
+	require.NoError(t, memfs.WriteFile("fixture.txt", []byte("first"), 0o644))
+
+	err := memfs.WriteFile("fixture.txt", []byte("second"), 0o644)
+	assert.ErrorIs(t, err, fs.ErrExist)
+	err = memfs.WriteLazyFile("fixture.txt", func() (io.Reader, error) {
+		return strings.NewReader("lazy"), nil
+	}, 0o644)
+	assert.ErrorIs(t, err, fs.ErrExist)
+
+	data, err := memfs.ReadFile("fixture.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	memfs = New()
+	require.NoError(t, memfs.WriteFile("fixture.txt", []byte("first"), 0o644))
+	require.NoError(t, memfs.WriteFile("fixture.txt", []byte("second"), 0o644))
+}
+
+func Test_OpenHandles(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	require.NoError(t, memfs.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	assert.Equal(t, 0, memfs.OpenHandles())
+
+	a, err := memfs.Open("dir/a.txt")
+	require.NoError(t, err)
+	sub, err := memfs.Sub("dir")
+	require.NoError(t, err)
+	b, err := sub.Open("b.txt")
+	require.NoError(t, err)
+	concat, err := memfs.OpenConcat("dir/a.txt", "dir/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, 3, memfs.OpenHandles())
+
+	_, err = memfs.ReadFile("dir/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, 3, memfs.OpenHandles())
+
+	require.NoError(t, a.Close())
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+	require.NoError(t, concat.Close())
+	assert.Equal(t, 0, memfs.OpenHandles())
+}
+
+func Test_WriteFileAt(t *testing.T) {
+	memfs := New(WithStaticDirTimes())
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	dirInfo, err := memfs.Stat("dir")
+	require.NoError(t, err)
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	require.NoError(t, memfs.WriteFileAt("dir/file.txt", []byte("content"), 0o644, mtime))
+
+	info, err := memfs.Stat("dir/file.txt")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+	assert.Equal(t, int64(7), info.Size())
+
+	updated, err := memfs.Stat("dir")
+	require.NoError(t, err)
+	assert.True(t, dirInfo.ModTime().Equal(updated.ModTime()))
+
+	later := mtime.Add(time.Hour)
+	require.NoError(t, memfs.WriteFileAt("dir/file.txt", []byte("changed"), 0o644, later))
+	info, err = memfs.Stat("dir/file.txt")
+	require.NoError(t, err)
+	assert.True(t, later.Equal(info.ModTime()))
+}
+
+func Test_DirTimes(t *testing.T) {
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	for _, static := range []bool{false, true} {
+		var opts []Option
+		if static {
+			opts = append(opts, WithStaticDirTimes())
+		}
+		memfs := New(opts...)
+		require.NoError(t, memfs.MkdirAll("dir", 0o700))
+		require.NoError(t, memfs.MkdirAll("other", 0o700))
+		reset := func() {
+			require.NoError(t, memfs.SetModified("dir", old))
+			require.NoError(t, memfs.SetModified("other", old))
+		}
+		changed := func(name string) bool {
+			info, err := memfs.Stat(name)
+			require.NoError(t, err)
+			return !info.ModTime().Equal(old)
+		}
+
+		reset()
+		require.NoError(t, memfs.WriteFile("dir/file.txt", []byte("content"), 0o644))
+		assert.Equal(t, !static, changed("dir"), "create")
+
+		reset()
+		require.NoError(t, memfs.WriteFile("dir/file.txt", []byte("changed"), 0o644))
+		_, err := memfs.ReadFile("dir/file.txt")
+		require.NoError(t, err)
+		_, err = memfs.ReadDir("dir")
+		require.NoError(t, err)
+		assert.False(t, changed("dir"), "overwrite and read")
+
+		reset()
+		require.NoError(t, memfs.MkdirAll("dir/sub", 0o700))
+		assert.Equal(t, !static, changed("dir"), "mkdir")
+
+		reset()
+		require.NoError(t, memfs.MkdirAll("dir/sub", 0o700))
+		assert.False(t, changed("dir"), "existing mkdir")
+
+		reset()
+		require.NoError(t, memfs.Rename("dir/file.txt", "other/file.txt"))
+		assert.Equal(t, !static, changed("dir"), "rename source")
+		assert.Equal(t, !static, changed("other"), "rename destination")
+
+		reset()
+		require.NoError(t, memfs.Remove("other/file.txt"))
+		assert.Equal(t, !static, changed("other"), "remove")
+		assert.False(t, changed("dir"), "unrelated")
+	}
+}
+
+func Test_MaxOpenHandles(t *testing.T) {
+	memfs := New(WithMaxOpenHandles(2))
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("content"), 0o644))
+
+	first, err := memfs.Open("file.txt")
+	require.NoError(t, err)
+	second, err := memfs.Open("file.txt")
+	require.NoError(t, err)
+
+	_, err = memfs.Open("file.txt")
+	assert.ErrorIs(t, err, syscall.EMFILE)
+	_, err = memfs.ReadFile("file.txt")
+	assert.ErrorIs(t, err, syscall.EMFILE)
+	_, err = memfs.OpenConcat("file.txt")
+	assert.ErrorIs(t, err, syscall.EMFILE)
+	assert.Equal(t, 2, memfs.OpenHandles())
+
+	dir, err := memfs.Open(".")
+	require.NoError(t, err)
+	require.NoError(t, dir.Close())
+
+	require.NoError(t, first.Close())
+	third, err := memfs.Open("file.txt")
+	require.NoError(t, err)
+	require.NoError(t, second.Close())
+	require.NoError(t, third.Close())
+}
+
+func Test_FileAsDirectory(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("existingfile", []byte("content"), 0o644))
+
+	_, err := memfs.Stat("existingfile/child")
+	assert.ErrorIs(t, err, ErrNotDir)
+	assert.ErrorIs(t, err, syscall.ENOTDIR)
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = memfs.Open("existingfile/child")
+	assert.ErrorIs(t, err, ErrNotDir)
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = memfs.Open("existingfile/child/grandchild")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	require.NoError(t, memfs.Symlink("existingfile", "link"))
+	_, err = memfs.Open("link/child")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	_, err = memfs.ReadDir("existingfile")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	assert.ErrorIs(t, memfs.WriteFile("existingfile/child", nil, 0o644), ErrNotDir)
+
+	_, err = memfs.Stat("missing/child")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_FileAndDirNameConflict(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("parent/x", 0o700))
+	assert.ErrorIs(t, memfs.WriteFile("parent/x", []byte("file"), 0o644), fs.ErrExist)
+	assert.ErrorIs(t, memfs.WriteLazyFile("parent/x", func() (io.Reader, error) {
+		return strings.NewReader("lazy"), nil
+	}, 0o644), fs.ErrExist)
+
+	require.NoError(t, memfs.WriteFile("parent/y", []byte("file"), 0o644))
+	assert.ErrorIs(t, memfs.MkdirAll("parent/y", 0o700), fs.ErrExist)
+
+	entries, err := memfs.ReadDir("parent")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.True(t, entries[0].IsDir())
+	assert.False(t, entries[1].IsDir())
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_FromMap(t *testing.T) {
+	memfs, err := FromMap(map[string]string{
+		"a/b.txt":     "hi",
+		"a/c/d.txt":   "deep",
+		"/top.txt":    "top",
+		"./dot/e.txt": "",
+	})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("a/c/d.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "deep", string(data))
+
+	info, err := memfs.Stat("a/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o644), info.Mode())
+
+	info, err = memfs.Stat("a/c")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o755, info.Mode())
+
+	_, err = memfs.Stat("top.txt")
+	assert.NoError(t, err)
+	_, err = memfs.Stat("dot/e.txt")
+	assert.NoError(t, err)
+
+	memfs, err = FromBytesMap(map[string][]byte{"bin": {0, 1, 2}})
+	require.NoError(t, err)
+	data, err = memfs.ReadFile("bin")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, data)
+}
+
+func Test_FromMapInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   error
+	}{
+		{name: "file and directory", files: map[string]string{"a": "", "a/b": ""}, err: fs.ErrExist},
+		{name: "same file", files: map[string]string{"a/b": "", "a//b": ""}, err: fs.ErrExist},
+		{name: "unsafe", files: map[string]string{"../escape": ""}, err: ErrUnsafePath},
+		{name: "root", files: map[string]string{".": ""}, err: fs.ErrInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := FromMap(test.files)
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func Test_ToMap(t *testing.T) {
+	files := map[string][]byte{
+		"a/b.txt":   []byte("hi"),
+		"a/c/d.txt": []byte("deep"),
+		"top.txt":   {},
+	}
+	memfs, err := FromBytesMap(files)
+	require.NoError(t, err)
+	require.NoError(t, memfs.MkdirAll("empty", 0o700))
+	require.NoError(t, memfs.Symlink("top.txt", "link"))
+
+	expected := map[string][]byte{}
+	for path, content := range files {
+		expected[strings.ReplaceAll(path, "/", separator)] = content
+	}
+	exported := memfs.ToMap()
+	assert.Equal(t, expected, exported)
+
+	// the content is a copy
+	exported[strings.ReplaceAll("a/b.txt", "/", separator)][0] = 'x'
+	data, err := memfs.ReadFile("a/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(data))
+
+	assert.Empty(t, New().ToMap())
+}
+
+func Test_WriteReaderHash(t *testing.T) {
+	memfs := New()
+	content := bytes.Repeat([]byte("streamed content "), 1000)
+
+	h := sha256.New()
+	require.NoError(t, memfs.WriteReaderHash("file.txt", bytes.NewReader(content), 0o644, h))
+
+	expected := sha256.Sum256(content)
+	assert.Equal(t, expected[:], h.Sum(nil))
+
+	data, err := memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func Test_WriteReaderError(t *testing.T) {
+	memfs := New()
+	err := memfs.WriteReader("file.txt", iotest.ErrReader(io.ErrUnexpectedEOF), 0o644)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = memfs.Stat("file.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ReadDirFilter(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir/sub.log", 0o700))
+	for _, name := range []string{"c.log", "a.log", "b.txt"} {
+		require.NoError(t, memfs.WriteFile("dir/"+name, nil, 0o644))
+	}
+
+	entries, err := memfs.ReadDirFilter("dir", func(entry fs.DirEntry) bool {
+		return strings.HasSuffix(entry.Name(), ".log")
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"a.log", "c.log", "sub.log"}, names)
+
+	entries, err = memfs.ReadDirFilter("dir", func(entry fs.DirEntry) bool {
+		return !entry.IsDir()
+	})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	_, err = memfs.ReadDirFilter("missing", func(fs.DirEntry) bool { return true })
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.ReadDirFilter("dir/a.log", func(fs.DirEntry) bool { return true })
+	assert.ErrorIs(t, err, ErrNotDir)
+}
+
+func Test_ReadDirDepth(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("root/a/b/c", 0o700))
+	require.NoError(t, memfs.WriteFile("root/a.txt", nil, 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/b/file.txt", nil, 0o644))
+	p := func(path string) string {
+		return strings.ReplaceAll(path, "/", separator)
+	}
+
+	paths, err := memfs.ReadDirDepth("root", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{p("root/a"), p("root/a.txt")}, paths)
+
+	paths, err = memfs.ReadDirDepth("root", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{p("root/a"), p("root/a.txt"), p("root/a/b")}, paths)
+
+	paths, err = memfs.ReadDirDepth("root/a", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{p("root/a/b"), p("root/a/b/c"), p("root/a/b/file.txt")}, paths)
+
+	paths, err = memfs.ReadDirDepth("root", 0)
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = memfs.ReadDirDepth("missing", 1)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.ReadDirDepth("root/a.txt", 1)
+	assert.ErrorIs(t, err, ErrNotDir)
+}
+
+func Test_ReadDirOnFile(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("test.txt", []byte("content"), 0o644))
+
+	_, err := memfs.ReadDir("test.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+	assert.ErrorIs(t, err, syscall.ENOTDIR)
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = fs.ReadDir(memfs, "test.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	_, err = memfs.ReadDir("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.False(t, errors.Is(err, ErrNotDir))
+}
+
+func Test_StatDoesNotOpen(t *testing.T) {
+	memfs := New()
+	var opens int
+	require.NoError(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		opens++
+		return strings.NewReader("content"), nil
+	}, 0o644))
+
+	info, err := memfs.Stat("lazy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "lazy.txt", info.Name())
+	f, err := memfs.Open("lazy.txt")
+	require.NoError(t, err)
+	_, err = f.Stat()
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, 0, opens)
+}
+
+func Test_SkipHidden(t *testing.T) {
+	setup := func(opts ...Option) *FS {
+		memfs := New(opts...)
+		require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("files/.git/objects", "/", separator), 0o700))
+		require.NoError(t, memfs.WriteFile(strings.ReplaceAll("files/.secret", "/", separator), []byte("secret"), 0o644))
+		require.NoError(t, memfs.WriteFile(strings.ReplaceAll("files/visible.txt", "/", separator), []byte("visible"), 0o644))
+		require.NoError(t, memfs.WriteFile(strings.ReplaceAll("files/.git/config", "/", separator), []byte("config"), 0o644))
+		return memfs
+	}
+	walk := func(memfs *FS) []string {
+		var paths []string
+		require.NoError(t, fs.WalkDir(memfs, ".", func(path string, _ fs.DirEntry, err error) error {
+			require.NoError(t, err)
+			paths = append(paths, path)
+			return nil
+		}))
+		return paths
+	}
+	names := func(entries []fs.DirEntry) []string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	t.Run("Off", func(t *testing.T) {
+		memfs := setup()
+		entries, err := memfs.ReadDir("files")
+		require.NoError(t, err)
+		assert.Equal(t, []string{".git", ".secret", "visible.txt"}, names(entries))
+		assert.Contains(t, walk(memfs), "files/.secret")
+		assert.Contains(t, walk(memfs), "files/.git/config")
+	})
+
+	t.Run("On", func(t *testing.T) {
+		memfs := setup(WithSkipHidden())
+		entries, err := memfs.ReadDir("files")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"visible.txt"}, names(entries))
+
+		entries, err = memfs.ReadDirFilter("files", func(fs.DirEntry) bool { return true })
+		require.NoError(t, err)
+		assert.Equal(t, []string{"visible.txt"}, names(entries))
+
+		assert.Equal(t, []string{".", "files", "files/visible.txt"}, walk(memfs))
+
+		files, err := memfs.ReadDirFiles("files")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"visible.txt"}, files)
+		dirs, err := memfs.ReadDirDirs("files")
+		require.NoError(t, err)
+		assert.Empty(t, dirs)
+
+		paths, err := memfs.ReadDirDepth(".", -1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"files", strings.ReplaceAll("files/visible.txt", "/", separator)}, paths)
+
+		data, err := memfs.ReadFile(strings.ReplaceAll("files/.secret", "/", separator))
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(data))
+	})
+}
+
 func Test_MkdirAllRoot(t *testing.T) {
 	memfs := New()
 	err := memfs.MkdirAll(".", 0o644)
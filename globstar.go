@@ -0,0 +1,128 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GlobStar returns the sorted paths of all files and directories matching pattern, which is a sequence of
+// segments separated by "/" or the separator of the filesystem. A segment of "**" matches zero or more
+// directories, other segments are matched against a single name using the syntax of filepath.Match. A trailing
+// "**" matches everything beneath the preceding directories. Symbolic links to directories are not followed.
+// A malformed pattern returns an error wrapping filepath.ErrBadPattern.
+func (m *FS) GlobStar(pattern string) ([]string, error) {
+	segments, err := m.globStarSegments(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches := map[string]struct{}{}
+	m.dir.globStar("", segments, matches)
+	paths := make([]string, 0, len(matches))
+	for path := range matches {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return m.listable(paths), nil
+}
+
+// WalkMatch walks the named directory and returns the entries beneath it whose paths relative to root match
+// pattern, along with their paths relative to the root of the filesystem, sorted by path. The pattern has the
+// syntax accepted by GlobStar, so "**/*.so" matches every file ending in ".so" at any depth beneath root. The
+// entries carry the metadata that Stat would return, avoiding a second lookup for each match. Symbolic links to
+// directories are not followed. A malformed pattern returns an error wrapping filepath.ErrBadPattern.
+func (m *FS) WalkMatch(root, pattern string) ([]fs.DirEntry, []string, error) {
+	segments, err := m.globStarSegments(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, rootPath, err := m.walkRoot("walkmatch", root)
+	if err != nil {
+		return nil, nil, err
+	}
+	matches := map[string]struct{}{}
+	d.globStar("", segments, matches)
+	relative := make([]string, 0, len(matches))
+	for path := range matches {
+		relative = append(relative, path)
+	}
+	sort.Strings(relative)
+
+	sep := m.dir.sep()
+	entries := make([]fs.DirEntry, 0, len(relative))
+	paths := make([]string, 0, len(relative))
+	for _, path := range relative {
+		var info fs.FileInfo
+		if f, err := d.getFile(path); err == nil {
+			info = f.stat()
+		} else if sub, err := d.getDir(path); err == nil {
+			info, _ = sub.Stat()
+		} else {
+			// removed since it was matched
+			continue
+		}
+		entries = append(entries, info.(fs.DirEntry))
+		paths = append(paths, joinPath(rootPath, path, sep))
+	}
+	return entries, paths, nil
+}
+
+// globStarSegments splits a pattern accepted by GlobStar into its segments, checking that each is well formed
+func (m *FS) globStarSegments(pattern string) ([]string, error) {
+	sep := m.dir.sep()
+	segments := strings.Split(fromSlash(pattern, sep), sep)
+	for _, segment := range segments {
+		if segment == "**" {
+			continue
+		}
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return segments, nil
+}
+
+// globStar adds the paths of the entries beneath d matching segments to matches, where path is the path of d
+func (d *dir) globStar(path string, segments []string, matches map[string]struct{}) {
+	sep := d.sep()
+	segment, rest := segments[0], segments[1:]
+	if segment == "**" {
+		if len(rest) == 0 {
+			_ = d.walk(path, func(child string, _ *dir, _ *file) error {
+				if child != path {
+					matches[child] = struct{}{}
+				}
+				return nil
+			})
+			return
+		}
+		d.globStar(path, rest, matches)
+		for _, name := range d.dirNames() {
+			if sub, err := d.getDir(name); err == nil {
+				sub.globStar(joinPath(path, name, sep), segments, matches)
+			}
+		}
+		return
+	}
+
+	for _, name := range d.dirNames() {
+		if ok, _ := filepath.Match(segment, name); !ok {
+			continue
+		}
+		child := joinPath(path, name, sep)
+		if len(rest) == 0 {
+			matches[child] = struct{}{}
+		} else if sub, err := d.getDir(name); err == nil {
+			sub.globStar(child, rest, matches)
+		}
+	}
+	if len(rest) == 0 {
+		for _, name := range d.fileNames() {
+			if ok, _ := filepath.Match(segment, name); ok {
+				matches[joinPath(path, name, sep)] = struct{}{}
+			}
+		}
+	}
+}
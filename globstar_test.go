@@ -0,0 +1,153 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GlobStar(t *testing.T) {
+	memfs, err := FromMap(map[string]string{
+		"config.yaml":               "",
+		"app/values.yaml":           "",
+		"app/templates/deploy.yaml": "",
+		"app/templates/notes.txt":   "",
+		"app/charts/db/values.yaml": "",
+		"docs/readme.md":            "",
+	})
+	require.NoError(t, err)
+	require.NoError(t, memfs.Symlink("app", "link"))
+
+	tests := []struct {
+		pattern string
+		matches []string
+	}{
+		{pattern: "**/*.yaml", matches: []string{"app/charts/db/values.yaml", "app/templates/deploy.yaml", "app/values.yaml", "config.yaml"}},
+		{pattern: "app/**/values.yaml", matches: []string{"app/charts/db/values.yaml", "app/values.yaml"}},
+		{pattern: "app/**", matches: []string{"app/charts", "app/charts/db", "app/charts/db/values.yaml", "app/templates", "app/templates/deploy.yaml", "app/templates/notes.txt", "app/values.yaml"}},
+		{pattern: "*/templates/*.???", matches: []string{"app/templates/notes.txt"}},
+		{pattern: "**/[dn]*", matches: []string{"app/charts/db", "app/templates/deploy.yaml", "app/templates/notes.txt", "docs"}},
+		{pattern: "**/**/readme.md", matches: []string{"docs/readme.md"}},
+		{pattern: "*", matches: []string{"app", "config.yaml", "docs", "link"}},
+		{pattern: "missing/**/*.yaml", matches: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			expected := make([]string, 0, len(test.matches))
+			for _, match := range test.matches {
+				expected = append(expected, strings.ReplaceAll(match, "/", separator))
+			}
+			matches, err := memfs.GlobStar(test.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, expected, matches)
+		})
+	}
+}
+
+func Test_GlobStarInvalid(t *testing.T) {
+	_, err := New().GlobStar("**/[a-")
+	assert.ErrorIs(t, err, filepath.ErrBadPattern)
+	assert.Contains(t, err.Error(), "**/[a-")
+}
+
+func Test_WalkMatch(t *testing.T) {
+	memfs, err := FromMap(map[string]string{
+		"config.yaml":               "top",
+		"app/values.yaml":           "values",
+		"app/templates/deploy.yaml": "deploy",
+		"app/templates/notes.txt":   "notes",
+		"app/charts/db/values.yaml": "db",
+		"docs/readme.md":            "readme",
+	})
+	require.NoError(t, err)
+	require.NoError(t, memfs.Symlink("app", "link"))
+
+	t.Run("Agrees with GlobStar", func(t *testing.T) {
+		for _, pattern := range []string{"**/*.yaml", "app/**/values.yaml", "app/**", "*/templates/*.???", "**/[dn]*", "*", "missing/**"} {
+			expected, err := memfs.GlobStar(pattern)
+			require.NoError(t, err)
+			entries, paths, err := memfs.WalkMatch(".", pattern)
+			require.NoError(t, err)
+			assert.Equal(t, expected, paths, pattern)
+			require.Len(t, entries, len(paths), pattern)
+			for i, entry := range entries {
+				assert.Equal(t, filepath.Base(paths[i]), entry.Name(), pattern)
+			}
+		}
+	})
+
+	t.Run("Relative to root", func(t *testing.T) {
+		entries, paths, err := memfs.WalkMatch("app", "**/values.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			strings.ReplaceAll("app/charts/db/values.yaml", "/", separator),
+			strings.ReplaceAll("app/values.yaml", "/", separator),
+		}, paths)
+		info, err := entries[1].Info()
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), info.Size())
+		assert.False(t, entries[1].IsDir())
+
+		entries, paths, err = memfs.WalkMatch("app", "*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			strings.ReplaceAll("app/charts", "/", separator),
+			strings.ReplaceAll("app/templates", "/", separator),
+			strings.ReplaceAll("app/values.yaml", "/", separator),
+		}, paths)
+		assert.True(t, entries[0].IsDir())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := memfs.WalkMatch(".", "**/[a-")
+		assert.ErrorIs(t, err, filepath.ErrBadPattern)
+		_, _, err = memfs.WalkMatch("missing", "**")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func Test_GlobStarAgreesWithWalkMatch(t *testing.T) {
+	memfs, err := FromMap(map[string]string{
+		"config.yaml":               "",
+		"app/values.yaml":           "",
+		"app/templates/deploy.yaml": "",
+		"app/charts/db/values.yaml": "",
+		"app/**/literal.txt":        "",
+	})
+	require.NoError(t, err)
+	require.NoError(t, memfs.MkdirAll("empty", 0o700))
+	require.NoError(t, memfs.Symlink("app", "link"))
+
+	for _, pattern := range []string{
+		"**",
+		"**/*.yaml",
+		"**/**",
+		"app/**",
+		"app/**/",
+		"**/values.yaml",
+		"*/**/*",
+		"app/[*][*]/*",
+		"",
+		"link/**",
+		"missing/**",
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			globbed, err := memfs.GlobStar(pattern)
+			require.NoError(t, err)
+			_, walked, err := memfs.WalkMatch(".", pattern)
+			require.NoError(t, err)
+			assert.Equal(t, globbed, walked)
+		})
+	}
+
+	for _, pattern := range []string{"[", "**/[", "app/[/**"} {
+		_, err := memfs.GlobStar(pattern)
+		assert.ErrorIs(t, err, filepath.ErrBadPattern, pattern)
+		_, _, err = memfs.WalkMatch(".", pattern)
+		assert.ErrorIs(t, err, filepath.ErrBadPattern, pattern)
+	}
+}
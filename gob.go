@@ -0,0 +1,121 @@
+package memoryfs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+type gobTree struct {
+	Entries []gobEntry
+}
+
+type gobEntry struct {
+	Path     string
+	Mode     fs.FileMode
+	Modified time.Time
+	Created  time.Time
+	Content  []byte
+
+	Immutable bool
+}
+
+// GobEncode implements gob.GobEncoder, allowing an *FS to be encoded directly or as part of a larger gob stream.
+// The encoding captures the entire tree, including names, content, modes, timestamps and whether files are
+// immutable, and the mode and timestamps of the root directory itself. The content of lazy files is read during
+// encoding. Values set via SetSys are not encoded.
+func (m *FS) GobEncode() ([]byte, error) {
+	var tree gobTree
+	if err := m.dir.walk("", func(path string, d *dir, f *file) error {
+		if d != nil {
+			info, _ := d.Stat()
+			tree.Entries = append(tree.Entries, gobEntryFromInfo(path, info.(fileinfo), nil))
+			return nil
+		}
+		content, err := f.readAll()
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+		tree.Entries = append(tree.Entries, gobEntryFromInfo(path, f.stat().(fileinfo), content))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buffer).Encode(tree); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, replacing the content of the filesystem with the decoded tree.
+func (m *FS) GobDecode(data []byte) error {
+	var tree gobTree
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tree); err != nil {
+		return err
+	}
+
+	decoded := New()
+	if m.dir != nil {
+		decoded.dir.tree.opts = m.dir.tree.opts
+	}
+
+	for _, entry := range tree.Entries {
+		if entry.Path == "" {
+			continue
+		}
+		if entry.Mode.IsDir() {
+			if err := decoded.dir.MkdirAll(entry.Path, entry.Mode); err != nil {
+				return fmt.Errorf("failed to create directory '%s': %w", entry.Path, err)
+			}
+			continue
+		}
+		if err := decoded.dir.WriteFile(entry.Path, entry.Content, entry.Mode); err != nil {
+			return fmt.Errorf("failed to create file '%s': %w", entry.Path, err)
+		}
+	}
+
+	// timestamps are applied once the tree is complete, as creating children updates the parent, and so is
+	// immutability, which would prevent the tree being built. The entry with an empty path is the root.
+	for _, entry := range tree.Entries {
+		if entry.Mode.IsDir() {
+			d, err := decoded.dir.getDir(entry.Path)
+			if err != nil {
+				return err
+			}
+			d.Lock()
+			d.info.mode = entry.Mode
+			d.info.modified = entry.Modified
+			d.info.created = entry.Created
+			d.Unlock()
+			continue
+		}
+		f, err := decoded.dir.getFile(entry.Path)
+		if err != nil {
+			return err
+		}
+		f.Lock()
+		f.info.modified = entry.Modified
+		f.info.created = entry.Created
+		f.info.immutable = entry.Immutable
+		f.Unlock()
+	}
+
+	m.dir = decoded.dir
+	return nil
+}
+
+func gobEntryFromInfo(path string, info fileinfo, content []byte) gobEntry {
+	return gobEntry{
+		Path:     path,
+		Mode:     info.mode,
+		Modified: info.modified,
+		Created:  info.created,
+		Content:  content,
+
+		Immutable: info.immutable,
+	}
+}
@@ -0,0 +1,103 @@
+package memoryfs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GobRoundTripNested(t *testing.T) {
+	type container struct {
+		Name string
+		FS   *FS
+	}
+
+	modified := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	created := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o750))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("hello world"), 0o600))
+	require.NoError(t, memfs.WriteFile("empty.txt", nil, 0o644))
+	require.NoError(t, memfs.Symlink("a/b/file.txt", "link"))
+	require.NoError(t, memfs.SetModified("a/b/file.txt", modified))
+	require.NoError(t, memfs.Chbtime("a/b/file.txt", created))
+	require.NoError(t, memfs.SetModified("a", modified))
+	require.NoError(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		return strings.NewReader("lazy content"), nil
+	}, 0o644))
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, gob.NewEncoder(buffer).Encode(container{Name: "test", FS: memfs}))
+
+	var decoded container
+	require.NoError(t, gob.NewDecoder(buffer).Decode(&decoded))
+	assert.Equal(t, "test", decoded.Name)
+	require.NotNil(t, decoded.FS)
+
+	data, err := decoded.FS.ReadFile("a/b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	info, err := decoded.FS.Stat("a/b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+	assert.True(t, created.Equal(info.(fileinfo).Created()))
+
+	info, err = decoded.FS.Stat("a")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o750)|fs.ModeDir, info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	info, err = decoded.FS.Stat("empty.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	data, err = decoded.FS.ReadFile("lazy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "lazy content", string(data))
+
+	target, err := decoded.FS.ReadLink("link")
+	require.NoError(t, err)
+	assert.Equal(t, "a/b/file.txt", target)
+
+	data, err = decoded.FS.ReadFile("link")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func Test_GobRoundTripRootAndImmutable(t *testing.T) {
+	modified := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	memfs := New(WithRootMode(0o751))
+	require.NoError(t, memfs.WriteFile("locked.txt", []byte("locked"), 0o644))
+	require.NoError(t, memfs.WriteFile("open.txt", []byte("open"), 0o644))
+	require.NoError(t, memfs.SetImmutable("locked.txt", true))
+	require.NoError(t, memfs.SetModified(".", modified))
+
+	data, err := memfs.GobEncode()
+	require.NoError(t, err)
+	decoded := &FS{}
+	require.NoError(t, decoded.GobDecode(data))
+
+	info, err := decoded.Stat(".")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o751)|fs.ModeDir, info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	info, err = decoded.Stat("locked.txt")
+	require.NoError(t, err)
+	assert.True(t, info.(fileinfo).Immutable())
+	assert.ErrorIs(t, decoded.WriteFile("locked.txt", nil, 0o644), ErrImmutable)
+	info, err = decoded.Stat("open.txt")
+	require.NoError(t, err)
+	assert.False(t, info.(fileinfo).Immutable())
+}
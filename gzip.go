@@ -0,0 +1,65 @@
+package memoryfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+)
+
+type gzipWriter struct {
+	fs     *FS
+	path   string
+	buffer bytes.Buffer
+	gw     *gzip.Writer
+	closed bool
+}
+
+// CreateGz returns a writer which gzip-compresses the data written to it. When the writer is closed, the compressed
+// data is written to the named file with mode 0o644, as WriteFile does, so Stat reports the compressed size. The
+// name is used as given, without adding a ".gz" extension. The compression level is set with WithGzipLevel.
+// An error is returned if the path is a directory or its parent directory does not exist.
+func (m *FS) CreateGz(name string) (io.WriteCloser, error) {
+	path, err := m.realpath("creategz", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, &fs.PathError{Op: "creategz", Path: name, Err: ErrIsDir}
+	}
+	if _, err := m.dir.getDir(path); err == nil {
+		return nil, &fs.PathError{Op: "creategz", Path: path, Err: ErrIsDir}
+	}
+	parentPath, _ := splitPath(path, m.dir.sep())
+	if _, err := m.dir.getDir(parentPath); err != nil {
+		return nil, &fs.PathError{Op: "creategz", Path: path, Err: err}
+	}
+	w := &gzipWriter{
+		fs:   m,
+		path: path,
+	}
+	w.gw, err = gzip.NewWriterLevel(&w.buffer, m.dir.tree.opts.gzipLevel)
+	if err != nil {
+		return nil, &fs.PathError{Op: "creategz", Path: path, Err: err}
+	}
+	return w, nil
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if g.closed {
+		return 0, fs.ErrClosed
+	}
+	return g.gw.Write(p)
+}
+
+// Close flushes the compressed data and writes it to the file
+func (g *gzipWriter) Close() error {
+	if g.closed {
+		return fs.ErrClosed
+	}
+	g.closed = true
+	if err := g.gw.Close(); err != nil {
+		return &fs.PathError{Op: "creategz", Path: g.path, Err: err}
+	}
+	return g.fs.WriteFile(g.path, g.buffer.Bytes(), defaultFilePerm)
+}
@@ -0,0 +1,75 @@
+package memoryfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CreateGz(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("logs", 0o700))
+
+	content := strings.Repeat("hello world\n", 100)
+	w, err := memfs.CreateGz("logs/app.log.gz")
+	require.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader(content))
+	require.NoError(t, err)
+
+	_, err = memfs.Stat("logs/app.log.gz")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, w.Close())
+	assert.ErrorIs(t, w.Close(), fs.ErrClosed)
+	_, err = w.Write([]byte("too late"))
+	assert.ErrorIs(t, err, fs.ErrClosed)
+
+	data, err := memfs.ReadFile("logs/app.log.gz")
+	require.NoError(t, err)
+	info, err := memfs.Stat("logs/app.log.gz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size())
+	assert.Less(t, info.Size(), int64(len(content)))
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(decompressed))
+}
+
+func Test_CreateGzLevel(t *testing.T) {
+	content := strings.Repeat("hello world\n", 100)
+	sizes := map[int]int64{}
+	for _, level := range []int{gzip.NoCompression, gzip.BestCompression} {
+		memfs := New(WithGzipLevel(level))
+		w, err := memfs.CreateGz("file.gz")
+		require.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		info, err := memfs.Stat("file.gz")
+		require.NoError(t, err)
+		sizes[level] = info.Size()
+	}
+	assert.Less(t, sizes[gzip.BestCompression], sizes[gzip.NoCompression])
+
+	_, err := New(WithGzipLevel(42)).CreateGz("file.gz")
+	assert.Error(t, err)
+}
+
+func Test_CreateGzErrors(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+
+	_, err := memfs.CreateGz("dir")
+	assert.ErrorIs(t, err, ErrIsDir)
+	_, err = memfs.CreateGz("missing/file.gz")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,42 @@
+package memoryfs
+
+import "io/fs"
+
+// SetImmutable marks the named file as immutable or not, emulating the immutable attribute of Linux filesystems
+// (chattr +i). While a file is immutable, writing, appending to, removing or renaming it, replacing it by renaming
+// another file over it, and removing a directory containing it, all fail with an error wrapping ErrImmutable,
+// which also matches fs.ErrPermission. Reading is unaffected. Whether a file is immutable is reported by the
+// Immutable method of the fs.FileInfo returned by Stat. Symbolic links are followed.
+func (m *FS) SetImmutable(name string, immutable bool) error {
+	path, err := m.realpath("setimmutable", name, true)
+	if err != nil {
+		return err
+	}
+	f, err := m.dir.getFile(path)
+	if err != nil {
+		if _, dirErr := m.dir.getDir(path); dirErr == nil {
+			return &fs.PathError{Op: "setimmutable", Path: path, Err: ErrIsDir}
+		}
+		return &fs.PathError{Op: "setimmutable", Path: path, Err: err}
+	}
+	f.Lock()
+	f.info.immutable = immutable
+	f.Unlock()
+	return nil
+}
+
+func (f *file) isImmutable() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.info.immutable
+}
+
+// containsImmutable reports whether any file beneath d is immutable
+func (d *dir) containsImmutable() bool {
+	return d.walk("", func(_ string, _ *dir, f *file) error {
+		if f != nil && f.isImmutable() {
+			return fs.ErrPermission
+		}
+		return nil
+	}) != nil
+}
@@ -0,0 +1,127 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type immutableInfo interface {
+	Immutable() bool
+}
+
+func newImmutableFS(t *testing.T, opts ...Option) *FS {
+	memfs := New(opts...)
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("a/b", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/b/locked.txt", "/", separator), []byte("locked"), 0o600))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/other.txt", "/", separator), []byte("other"), 0o600))
+	require.NoError(t, memfs.SetImmutable(strings.ReplaceAll("a/b/locked.txt", "/", separator), true))
+	return memfs
+}
+
+func Test_SetImmutableBlocksModification(t *testing.T) {
+	locked := strings.ReplaceAll("a/b/locked.txt", "/", separator)
+	other := strings.ReplaceAll("a/other.txt", "/", separator)
+
+	tests := map[string]func(memfs *FS) error{
+		"write": func(memfs *FS) error {
+			return memfs.WriteFile(locked, []byte("changed"), 0o600)
+		},
+		"lazy write": func(memfs *FS) error {
+			return memfs.WriteLazyFile(locked, func() (io.Reader, error) {
+				return strings.NewReader("changed"), nil
+			}, 0o600)
+		},
+		"append": func(memfs *FS) error {
+			w, err := memfs.AppendWriter(locked)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("changed")); err != nil {
+				return err
+			}
+			return w.Close()
+		},
+		"remove": func(memfs *FS) error {
+			return memfs.Remove(locked)
+		},
+		"remove all": func(memfs *FS) error {
+			return memfs.RemoveAll("a")
+		},
+		"rename": func(memfs *FS) error {
+			return memfs.Rename(locked, strings.ReplaceAll("a/moved.txt", "/", separator))
+		},
+		"rename over": func(memfs *FS) error {
+			return memfs.Rename(other, locked)
+		},
+	}
+
+	for name, op := range tests {
+		t.Run(name, func(t *testing.T) {
+			memfs := newImmutableFS(t)
+			err := op(memfs)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrImmutable)
+			assert.ErrorIs(t, err, fs.ErrPermission)
+			assert.ErrorIs(t, err, syscall.EPERM)
+
+			data, err := memfs.ReadFile(locked)
+			require.NoError(t, err)
+			assert.Equal(t, "locked", string(data))
+			_, err = memfs.Stat(other)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_SetImmutableTrash(t *testing.T) {
+	memfs := newImmutableFS(t, WithTrash())
+	locked := strings.ReplaceAll("a/b/locked.txt", "/", separator)
+
+	assert.ErrorIs(t, memfs.Remove(locked), ErrImmutable)
+	assert.ErrorIs(t, memfs.RemoveAll("a"), ErrImmutable)
+
+	data, err := memfs.ReadFile(locked)
+	require.NoError(t, err)
+	assert.Equal(t, "locked", string(data))
+}
+
+func Test_SetImmutableStat(t *testing.T) {
+	memfs := newImmutableFS(t)
+	locked := strings.ReplaceAll("a/b/locked.txt", "/", separator)
+	other := strings.ReplaceAll("a/other.txt", "/", separator)
+
+	info, err := memfs.Stat(locked)
+	require.NoError(t, err)
+	assert.True(t, info.(immutableInfo).Immutable())
+
+	info, err = memfs.Stat(other)
+	require.NoError(t, err)
+	assert.False(t, info.(immutableInfo).Immutable())
+}
+
+func Test_SetImmutableCleared(t *testing.T) {
+	memfs := newImmutableFS(t)
+	locked := strings.ReplaceAll("a/b/locked.txt", "/", separator)
+
+	require.NoError(t, memfs.SetImmutable(locked, false))
+	require.NoError(t, memfs.WriteFile(locked, []byte("changed"), 0o600))
+
+	data, err := memfs.ReadFile(locked)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(data))
+
+	require.NoError(t, memfs.Remove(locked))
+}
+
+func Test_SetImmutableInvalid(t *testing.T) {
+	memfs := newImmutableFS(t)
+
+	assert.ErrorIs(t, memfs.SetImmutable("missing.txt", true), fs.ErrNotExist)
+	assert.ErrorIs(t, memfs.SetImmutable("a", true), ErrIsDir)
+}
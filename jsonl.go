@@ -0,0 +1,64 @@
+package memoryfs
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// metaRecord is a line written by WriteMetaJSONL
+type metaRecord struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Mode     string    `json:"mode"`
+	Modified time.Time `json:"mtime"`
+	IsDir    bool      `json:"isDir"`
+	Target   string    `json:"target,omitempty"`
+}
+
+// WriteMetaJSONL writes the metadata of every file and directory in the filesystem to w as JSON lines: one object
+// per line with the slash-separated path, size, mode (as formatted by fs.FileMode.String), modification time and
+// whether the entry is a directory, along with the target of symbolic links. Lines are sorted by path, so "a.txt"
+// comes before "a/b", and are only written once the whole filesystem has been walked. Content is not read, so sizes
+// are not reliable for lazy files.
+func (m *FS) WriteMetaJSONL(w io.Writer) error {
+	var records []metaRecord
+	err := m.dir.walk("", func(path string, d *dir, f *file) error {
+		if path == "" {
+			return nil
+		}
+		var info fs.FileInfo
+		record := metaRecord{
+			Path: toSlash(path, m.dir.sep()),
+		}
+		if d != nil {
+			info, _ = d.Stat()
+		} else {
+			info = f.stat()
+			if f.isSymlink() {
+				record.Target = f.linkTarget()
+			}
+		}
+		record.Size = info.Size()
+		record.Mode = info.Mode().String()
+		record.Modified = info.ModTime()
+		record.IsDir = info.IsDir()
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Path < records[j].Path
+	})
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package memoryfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteMetaJSONL(t *testing.T) {
+	memfs := New()
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, memfs.MkdirAll("a/b", 0o755))
+	require.NoError(t, memfs.WriteFileAt("a/b/file.txt", []byte("content"), 0o644, modified))
+	require.NoError(t, memfs.WriteFileAt("a.txt", nil, 0o600, modified))
+	require.NoError(t, memfs.Symlink("a.txt", "link"))
+
+	var buffer bytes.Buffer
+	require.NoError(t, memfs.WriteMetaJSONL(&buffer))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	var records []map[string]interface{}
+	for _, line := range lines {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 5)
+
+	var paths []string
+	for _, record := range records {
+		paths = append(paths, record["path"].(string))
+	}
+	assert.Equal(t, []string{"a", "a.txt", "a/b", "a/b/file.txt", "link"}, paths)
+
+	assert.Equal(t, map[string]interface{}{
+		"path":  "a/b/file.txt",
+		"size":  float64(7),
+		"mode":  "-rw-r--r--",
+		"mtime": "2020-01-02T03:04:05Z",
+		"isDir": false,
+	}, records[3])
+	assert.Equal(t, true, records[0]["isDir"])
+	assert.Equal(t, "drwxr-xr-x", records[0]["mode"])
+	assert.Equal(t, "a.txt", records[4]["target"])
+	assert.Equal(t, "Lrwxrwxrwx", records[4]["mode"])
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func Test_WriteMetaJSONLError(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", nil, 0o644))
+	assert.EqualError(t, memfs.WriteMetaJSONL(failingWriter{}), "write failed")
+}
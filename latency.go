@@ -0,0 +1,50 @@
+package memoryfs
+
+import (
+	"context"
+	"io/fs"
+	"sync/atomic"
+	"time"
+)
+
+// SetReadLatency makes every Read call on a file handle returned by Open, OpenNoFollow or OpenContext sleep for d
+// before returning data, to simulate slow storage when testing timeouts and cancellation. A duration of zero or
+// less removes the latency, which is the default. The latency applies to handles which are already open.
+func (m *FS) SetReadLatency(d time.Duration) {
+	atomic.StoreInt64(&m.dir.tree.readLatency, int64(d))
+}
+
+// OpenContext opens the named file for reading as Open does, returning a file which stops serving reads once ctx
+// is done: from then on, Read returns ctx.Err(). If a read latency has been set with SetReadLatency, a Read which
+// is sleeping when ctx becomes done returns ctx.Err() immediately.
+func (m *FS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if access, ok := f.(*fileAccess); ok {
+		access.ctx = ctx
+	}
+	return f, nil
+}
+
+// delayRead sleeps for the configured read latency, returning early with ctx.Err() if ctx is done. A nil ctx is
+// never done.
+func (t *tree) delayRead(ctx context.Context) error {
+	d := time.Duration(atomic.LoadInt64(&t.readLatency))
+	if d <= 0 {
+		return nil
+	}
+	if ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,98 @@
+package memoryfs
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadLatency(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("content"), 0o644))
+	memfs.SetReadLatency(20 * time.Millisecond)
+
+	f, err := memfs.Open("file.txt")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	start := time.Now()
+	buffer := make([]byte, 3)
+	n, err := f.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "con", string(buffer[:n]))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	memfs.SetReadLatency(0)
+	start = time.Now()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "tent", string(data))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+}
+
+func Test_ReadLatencyContext(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("content"), 0o644))
+	memfs.SetReadLatency(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	f, err := memfs.OpenContext(ctx, "file.txt")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Read(make([]byte, 4))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	memfs.SetReadLatency(0)
+	_, err = f.Read(make([]byte, 4))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = memfs.OpenContext(context.Background(), "missing.txt")
+	assert.Error(t, err)
+}
+
+func Test_ReadLatencyContextCancelled(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("content"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := memfs.OpenContext(ctx, "file.txt")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	data := make([]byte, 4)
+	n, err := f.Read(data)
+	require.NoError(t, err)
+	assert.Equal(t, "cont", string(data[:n]))
+
+	memfs.SetReadLatency(time.Hour)
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.Read(data)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read was not aborted by cancellation")
+	}
+
+	memfs.SetReadLatency(0)
+	_, err = f.Read(data)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	g, err := memfs.OpenContext(ctx, "file.txt")
+	require.NoError(t, err)
+	defer func() { _ = g.Close() }()
+	_, err = g.Read(data)
+	assert.ErrorIs(t, err, context.Canceled)
+}
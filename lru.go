@@ -0,0 +1,68 @@
+package memoryfs
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache holds the content of files loaded from a mount when WithLRU is used, evicting the least recently used
+// content once the total size exceeds max. Content is held here rather than in the files themselves, so that
+// eviction never needs to lock a file.
+type lruCache struct {
+	sync.Mutex
+	max      int64
+	size     int64
+	order    *list.List // of *lruEntry, most recently used first
+	elements map[*file]*list.Element
+}
+
+type lruEntry struct {
+	file *file
+	data []byte
+}
+
+// get returns the cached content of f, marking it as the most recently used
+func (c *lruCache) get(f *file) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	element, ok := c.elements[f]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).data, true
+}
+
+// put caches the content of f, evicting the least recently used content until the cache is within its limit.
+// Content larger than the limit is not kept at all.
+func (c *lruCache) put(f *file, data []byte) {
+	c.Lock()
+	defer c.Unlock()
+	if c.elements == nil {
+		c.order = list.New()
+		c.elements = map[*file]*list.Element{}
+	}
+	c.removeLocked(f)
+	c.elements[f] = c.order.PushFront(&lruEntry{file: f, data: data})
+	c.size += int64(len(data))
+	for c.size > c.max && c.order.Len() > 0 {
+		c.removeLocked(c.order.Back().Value.(*lruEntry).file)
+	}
+}
+
+// remove drops any cached content of f, such as when it is overwritten or removed
+func (c *lruCache) remove(f *file) {
+	c.Lock()
+	defer c.Unlock()
+	c.removeLocked(f)
+}
+
+func (c *lruCache) removeLocked(f *file) {
+	element, ok := c.elements[f]
+	if !ok {
+		return
+	}
+	c.size -= int64(len(element.Value.(*lruEntry).data))
+	c.order.Remove(element)
+	delete(c.elements, f)
+}
@@ -0,0 +1,233 @@
+package memoryfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+)
+
+// mountSource identifies the location within a mounted fs.FS which provides the content of a directory or file
+// that has not yet been loaded into memory
+type mountSource struct {
+	src  fs.FS
+	path string    // slash-separated, as required by fs.FS
+	live bool      // file content is read from src on every open rather than cached
+	lru  *lruCache // file content is cached in lru rather than in the file, see WithLRU
+}
+
+// MountCached mounts src at the named directory, creating it if necessary. The mounted content is read from src
+// lazily and cached in memory: the listing of each directory is loaded the first time the directory is accessed,
+// which also provides the metadata reported by Stat, and the content of each file is loaded the first time it is
+// read. Afterwards src is no longer consulted for that directory or file, unless its content is evicted by
+// WithLRU. Writes are applied to memory only, shadowing the content of src, and entries which already exist in
+// memory take precedence over those in src.
+// Symbolic links in src are mounted if src implements ReadLink, and other irregular files are ignored.
+// Directories which fail to list are treated as empty.
+func (m *FS) MountCached(name string, src fs.FS) error {
+	if err := m.MkdirAll(name, defaultDirPerm); err != nil {
+		return err
+	}
+	path, err := m.realpath("mount", name, true)
+	if err != nil {
+		return err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return &fs.PathError{Op: "mount", Path: path, Err: err}
+	}
+	d.Lock()
+	d.source = &mountSource{src: src, path: "."}
+	if d.tree.opts.lruBytes > 0 {
+		d.source.lru = &d.tree.lru
+	}
+	d.Unlock()
+	d.invalidateSize()
+	return nil
+}
+
+// Wrap returns a writable filesystem backed by src, as if src were mounted with MountCached at the root of a new
+// filesystem created with the given options: content is read from src lazily and cached in memory, and writes are
+// applied to memory only, shadowing the content of src. The mode and modification time of the root are taken from
+// src when it can be statted.
+func Wrap(src fs.FS, opts ...Option) *FS {
+	m := New(opts...)
+	if info, err := fs.Stat(src, "."); err == nil {
+		m.dir.info.mode = info.Mode().Perm() | fs.ModeDir
+		m.dir.info.modified = info.ModTime()
+	}
+	m.dir.source = &mountSource{src: src, path: "."}
+	if m.dir.tree.opts.lruBytes > 0 {
+		m.dir.source.lru = &m.dir.tree.lru
+	}
+	return m
+}
+
+// SubCOW returns a writable view of the subtree rooted at dir which is isolated from m: writes and removals made
+// through the view only affect the view, while reads of untouched files fall through to m and so reflect its
+// current content. The listing and metadata of each directory are taken from m when the directory is first
+// accessed through the view. The returned value is an *FS.
+func (m *FS) SubCOW(dir string) (fs.FS, error) {
+	path, err := m.realpath("sub", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: path, Err: err}
+	}
+	info, _ := d.Stat()
+
+	cow := New()
+	cow.dir.tree.opts = m.dir.tree.opts
+	cow.dir.info.mode = info.Mode()
+	cow.dir.info.modified = info.ModTime()
+	cow.dir.source = &mountSource{src: &FS{dir: d}, path: ".", live: true}
+	return cow, nil
+}
+
+// populate loads the listing of a mounted directory into memory, if it has not already been loaded. It must be
+// called before the children of d are accessed, and without d being locked.
+func (d *dir) populate() {
+	d.RLock()
+	pending := d.source != nil
+	d.RUnlock()
+	if !pending {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	source := d.source
+	if source == nil {
+		return
+	}
+	d.source = nil
+
+	entries, err := fs.ReadDir(source.src, source.path)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := d.files[name]; ok {
+			continue
+		}
+		if _, ok := d.dirs[name]; ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		child := &mountSource{src: source.src, path: path.Join(source.path, name), live: source.live, lru: source.lru}
+		switch {
+		case info.IsDir():
+			sub := &dir{
+				info: fileinfo{
+					name:     name,
+					size:     0x100,
+					modified: info.ModTime(),
+					created:  info.ModTime(),
+					mode:     info.Mode().Perm() | fs.ModeDir,
+				},
+				tree:   d.tree,
+				source: child,
+				dirs:   map[string]*dir{},
+				files:  map[string]*file{},
+			}
+			sub.setParent(d)
+			d.dirs[name] = sub
+			d.tree.countEntries(1)
+		case info.Mode()&fs.ModeSymlink != 0:
+			links, ok := source.src.(readLinkFS)
+			if !ok {
+				continue
+			}
+			target, err := links.ReadLink(child.path)
+			if err != nil {
+				continue
+			}
+			f := &file{
+				info: fileinfo{
+					name:     name,
+					size:     int64(len(target)),
+					modified: info.ModTime(),
+					created:  info.ModTime(),
+					mode:     fs.ModeSymlink | 0o777,
+				},
+				content: []byte(target),
+			}
+			f.opener = f.openMemory
+			d.files[name] = f
+			d.tree.countEntries(1)
+		case info.Mode().IsRegular():
+			f := &file{
+				info: fileinfo{
+					name:     name,
+					size:     info.Size(),
+					modified: info.ModTime(),
+					created:  info.ModTime(),
+					mode:     info.Mode().Perm(),
+				},
+				source: child,
+			}
+			f.opener = f.openCached
+			d.files[name] = f
+			d.tree.countFile()
+			d.tree.countEntries(1)
+		}
+	}
+	d.invalidateSize()
+}
+
+// openCached is the opener for mounted files, which loads the content from the source into memory the first time
+// the file is read, or reads it from the source every time for live mounts. With WithLRU, the content is kept in
+// the cache instead and the source is retained, so the content can be loaded again once evicted. Like all openers,
+// it is called with f locked.
+func (f *file) openCached() (io.Reader, error) {
+	if f.source != nil && f.source.live {
+		return f.source.src.Open(f.source.path)
+	}
+	if f.source != nil && f.source.lru != nil {
+		if data, ok := f.source.lru.get(f); ok {
+			return bytes.NewReader(data), nil
+		}
+		data, err := f.source.read()
+		if err != nil {
+			return nil, err
+		}
+		f.info.size = int64(len(data))
+		f.source.lru.put(f, data)
+		return bytes.NewReader(data), nil
+	}
+	if f.source != nil {
+		data, err := f.source.read()
+		if err != nil {
+			return nil, err
+		}
+		f.content = data
+		f.info.size = int64(len(data))
+		f.source = nil
+	}
+	f.opener = f.openMemory
+	return f.openMemory()
+}
+
+// read returns the whole content of the file at the source
+func (s *mountSource) read() ([]byte, error) {
+	r, err := s.src.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return ioutil.ReadAll(r)
+}
+
+// uncache drops any content of f held by the LRU cache. It is called with f locked.
+func (f *file) uncache() {
+	if f.source != nil && f.source.lru != nil {
+		f.source.lru.remove(f)
+	}
+}
@@ -0,0 +1,267 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingFS records how many times each path is opened
+type countingFS struct {
+	fs.FS
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.FS.Open(name)
+}
+
+func (c *countingFS) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opens[name]
+}
+
+func newCountingFS() *countingFS {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &countingFS{
+		FS: fstest.MapFS{
+			"file.txt":          {Data: []byte("from source"), Mode: 0o640, ModTime: modified},
+			"nested/deep/a.txt": {Data: []byte("a"), Mode: 0o644, ModTime: modified},
+			"nested/b.txt":      {Data: []byte("bb"), Mode: 0o644, ModTime: modified},
+		},
+		opens: map[string]int{},
+	}
+}
+
+func Test_MountCached(t *testing.T) {
+	src := newCountingFS()
+	memfs := New()
+	require.NoError(t, memfs.MountCached("mnt", src))
+
+	info, err := memfs.Stat("mnt/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), info.Size())
+	assert.Equal(t, fs.FileMode(0o640), info.Mode())
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(info.ModTime()))
+	assert.Equal(t, 0, src.count("file.txt"))
+
+	for i := 0; i < 3; i++ {
+		data, err := memfs.ReadFile("mnt/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "from source", string(data))
+	}
+	assert.Equal(t, 1, src.count("file.txt"))
+
+	entries, err := memfs.ReadDir("mnt/nested")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "b.txt", entries[0].Name())
+	assert.Equal(t, "deep", entries[1].Name())
+	assert.True(t, entries[1].IsDir())
+
+	data, err := memfs.ReadFile("mnt/nested/deep/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	size, err := memfs.DirSize("mnt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), size)
+
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_MountCachedWritesShadowSource(t *testing.T) {
+	src := newCountingFS()
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("mnt", 0o700))
+	require.NoError(t, memfs.WriteFile("mnt/existing.txt", []byte("memory"), 0o644))
+	require.NoError(t, memfs.MountCached("mnt", src))
+
+	require.NoError(t, memfs.WriteFile("mnt/file.txt", []byte("from memory"), 0o644))
+	data, err := memfs.ReadFile("mnt/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from memory", string(data))
+	assert.Equal(t, 0, src.count("file.txt"))
+
+	require.NoError(t, memfs.Remove("mnt/nested/b.txt"))
+	_, err = memfs.Stat("mnt/nested/b.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err = memfs.ReadFile("mnt/existing.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "memory", string(data))
+
+	srcData, err := fs.ReadFile(src.FS, "file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from source", string(srcData))
+}
+
+func Test_MountCachedSnapshot(t *testing.T) {
+	src := newCountingFS()
+	memfs := New()
+	require.NoError(t, memfs.MountCached("mnt", src))
+
+	snapshot := memfs.Snapshot()
+	require.NoError(t, memfs.WriteFile("mnt/file.txt", []byte("changed"), 0o644))
+
+	data, err := fs.ReadFile(snapshot, "mnt/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from source", string(data))
+}
+
+func Test_MountCachedOnFile(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", nil, 0o644))
+	assert.Error(t, memfs.MountCached("file.txt", newCountingFS()))
+}
+
+func Test_SubCOW(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("project/src", 0o755))
+	require.NoError(t, memfs.WriteFile("project/src/main.go", []byte("package main"), 0o644))
+	require.NoError(t, memfs.WriteFile("project/README", []byte("readme"), 0o644))
+
+	view, err := memfs.SubCOW("project")
+	require.NoError(t, err)
+	cow := view.(*FS)
+
+	data, err := fs.ReadFile(cow, "src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(data))
+
+	// untouched files reflect the parent
+	require.NoError(t, memfs.WriteFile("project/src/main.go", []byte("package other"), 0o644))
+	data, err = fs.ReadFile(cow, "src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package other", string(data))
+
+	// writes and removals through the view shadow the parent
+	require.NoError(t, cow.WriteFile("src/main.go", []byte("package cow"), 0o644))
+	require.NoError(t, cow.WriteFile("src/new.go", []byte("package new"), 0o644))
+	require.NoError(t, cow.Remove("README"))
+
+	data, err = fs.ReadFile(cow, "src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package cow", string(data))
+	_, err = fs.Stat(cow, "README")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err = memfs.ReadFile("project/src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package other", string(data))
+	_, err = memfs.Stat("project/src/new.go")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.Stat("project/README")
+	assert.NoError(t, err)
+}
+
+func Test_SubCOWMissing(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("file.txt", nil, 0o644))
+
+	_, err := memfs.SubCOW("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.SubCOW("file.txt")
+	assert.ErrorIs(t, err, ErrNotDir)
+}
+
+func Test_MountCachedLRU(t *testing.T) {
+	src := newCountingFS()
+	memfs := New(WithLRU(12))
+	require.NoError(t, memfs.MountCached("mnt", src))
+
+	read := func(name string) string {
+		data, err := memfs.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, "from source", read("mnt/file.txt"))
+	assert.Equal(t, "from source", read("mnt/file.txt"))
+	assert.Equal(t, 1, src.count("file.txt"))
+
+	// 11 + 2 bytes exceeds the limit, evicting the least recently used file
+	assert.Equal(t, "bb", read("mnt/nested/b.txt"))
+	assert.Equal(t, "bb", read("mnt/nested/b.txt"))
+	assert.Equal(t, 1, src.count("nested/b.txt"))
+	assert.Equal(t, "from source", read("mnt/file.txt"))
+	assert.Equal(t, 2, src.count("file.txt"))
+	assert.Equal(t, "bb", read("mnt/nested/b.txt"))
+	assert.Equal(t, 2, src.count("nested/b.txt"))
+
+	// written files are never evicted
+	require.NoError(t, memfs.WriteFile("mnt/nested/b.txt", []byte("written"), 0o644))
+	require.NoError(t, memfs.WriteFile("big.txt", []byte("larger than the limit"), 0o644))
+	assert.Equal(t, "from source", read("mnt/file.txt"))
+	assert.Equal(t, "a", read("mnt/nested/deep/a.txt"))
+	assert.Equal(t, "written", read("mnt/nested/b.txt"))
+	assert.Equal(t, "larger than the limit", read("big.txt"))
+	assert.Equal(t, 2, src.count("nested/b.txt"))
+
+	info, err := memfs.Stat("mnt/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), info.Size())
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_Wrap(t *testing.T) {
+	src := newCountingFS()
+	memfs := Wrap(src)
+
+	data, err := memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from source", string(data))
+	assert.Equal(t, 1, src.count("file.txt"))
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("shadowed"), 0o600))
+	require.NoError(t, memfs.WriteFile("nested/new.txt", []byte("new"), 0o644))
+
+	data, err = memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "shadowed", string(data))
+	assert.Equal(t, 1, src.count("file.txt"))
+
+	source, err := fs.ReadFile(src, "file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from source", string(source))
+
+	entries, err := memfs.ReadDir("nested")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"b.txt", "deep", "new.txt"}, names)
+
+	data, err = memfs.ReadFile("nested/deep/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+}
+
+func Test_WrapRootInfo(t *testing.T) {
+	modified := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	memfs := Wrap(fstest.MapFS{
+		".":        {Mode: fs.ModeDir | 0o750, ModTime: modified},
+		"file.txt": {Data: []byte("hello")},
+	}, WithLRU(1024))
+
+	info, err := memfs.Stat(".")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, fs.ModeDir|0o750, info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	data, err := memfs.ReadFile("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
@@ -0,0 +1,185 @@
+package memoryfs
+
+import "io/fs"
+
+// Option configures an FS created with New
+type Option func(*options)
+
+type options struct {
+	skipUnsafePaths bool
+	rootName        string
+	rootMode        fs.FileMode
+	maxFiles        int
+	maxEntries      int
+	maxDepth        int
+	maxNameLen      int
+	maxOpenHandles  int
+	noClobber       bool
+	spillThreshold  int64
+	spillDir        string
+	gzipLevel       int
+	separator       string
+	accessLog       bool
+	eventLog        bool
+	trash           bool
+	lruBytes        int64
+	compression     bool
+	inheritDirMode  bool
+	staticDirTimes  bool
+	skipHidden      bool
+	capacity        int64
+}
+
+// WithSecureImport controls how importers such as ReadTar and ReadZip handle entries whose paths would escape
+// the root of the filesystem. Such entries are never written. If skip is true they are silently ignored,
+// otherwise the import is aborted with an error wrapping ErrUnsafePath (the default).
+func WithSecureImport(skip bool) Option {
+	return func(o *options) {
+		o.skipUnsafePaths = skip
+	}
+}
+
+// WithRootName sets the name reported for the root directory, which defaults to ".".
+// Paths equal to the root name, such as "/", are treated as the root in the same way as ".".
+func WithRootName(name string) Option {
+	return func(o *options) {
+		o.rootName = name
+	}
+}
+
+// WithRootMode sets the permission bits of the root directory, which default to 0o700.
+func WithRootMode(perm fs.FileMode) Option {
+	return func(o *options) {
+		o.rootMode = perm.Perm()
+	}
+}
+
+// WithMaxFiles limits the number of regular files the filesystem may hold. Once the limit is reached, creating a
+// new file fails with ErrTooManyFiles until existing files are removed. Overwriting a file does not count
+// against the limit. A limit of zero or less means unlimited, which is the default.
+func WithMaxFiles(max int) Option {
+	return func(o *options) {
+		o.maxFiles = max
+	}
+}
+
+// WithMaxEntries limits the total number of files, directories and symbolic links the filesystem may hold, not
+// counting the root, as a ceiling against archives which create enormous numbers of tiny entries. Once the limit
+// is reached, creating a new entry fails with ErrTooManyEntries until existing entries are removed. MkdirAll
+// creates none of the missing directories if they would not all fit. Overwriting a file does not count against
+// the limit, and entries loaded from a mount are counted but never rejected. A limit of zero or less means
+// unlimited, which is the default.
+func WithMaxEntries(max int) Option {
+	return func(o *options) {
+		o.maxEntries = max
+	}
+}
+
+// WithMaxDepth limits how deeply nested files and directories may be, counted as the number of components in
+// their path from the root of the filesystem (so "a/b.txt" has a depth of 2). Creating anything deeper fails
+// with ErrTooDeep, without creating any of the missing parent directories. A limit of zero or less means
+// unlimited, which is the default.
+func WithMaxDepth(max int) Option {
+	return func(o *options) {
+		o.maxDepth = max
+	}
+}
+
+// WithMaxNameLen limits the length in bytes of each component of the paths of files and directories, as most
+// filesystems limit names to 255 bytes. Creating or renaming to a path with a longer component fails with
+// ErrNameTooLong, without creating any of the missing parent directories. A limit of zero or less means
+// unlimited, which is the default.
+func WithMaxNameLen(max int) Option {
+	return func(o *options) {
+		o.maxNameLen = max
+	}
+}
+
+// WithMaxOpenHandles limits the number of file handles which may be open at once, as reported by OpenHandles, to
+// simulate file descriptor exhaustion. Once the limit is reached, opening a file fails with an error wrapping
+// syscall.EMFILE until a handle is closed. A limit of zero or less means unlimited, which is the default.
+func WithMaxOpenHandles(max int) Option {
+	return func(o *options) {
+		o.maxOpenHandles = max
+	}
+}
+
+// WithInheritedDirMode makes directories created implicitly, such as the missing parents created by WriteFileDeep
+// or when importing an archive entry whose parents are not in the archive, take the permissions of their nearest
+// existing ancestor rather than 0o755. Directories created with an explicit mode, such as by MkdirAll, are not
+// affected.
+func WithInheritedDirMode() Option {
+	return func(o *options) {
+		o.inheritDirMode = true
+	}
+}
+
+// WithStaticDirTimes stops the modification time of a directory from being updated when an entry within it is
+// created, removed or renamed, so that it only changes when set explicitly, such as with SetModified. This suits
+// importers which restore the times of directories before writing their contents. By default, directories behave
+// as on most operating systems: creating, removing or renaming an entry updates the modification time of the
+// directory containing it, while writing to an existing file does not.
+func WithStaticDirTimes() Option {
+	return func(o *options) {
+		o.staticDirTimes = true
+	}
+}
+
+// WithSkipHidden omits hidden entries, whose names begin with ".", from the results of ReadDir, ReadDirFilter,
+// ReadDirDirs, ReadDirFiles, ReadDirPaths and ReadDirDepth, and so from fs.WalkDir and WalkFollow, which list
+// directories with ReadDir. Hidden entries can still be opened, statted and written by name, and are matched by
+// Glob as with path.Match. By default, hidden entries are listed like any other.
+func WithSkipHidden() Option {
+	return func(o *options) {
+		o.skipHidden = true
+	}
+}
+
+// WithGzipLevel sets the compression level used by WriteTarGz, CreateGz and WithCompression, which is one of the levels accepted by
+// gzip.NewWriterLevel. The default is gzip.DefaultCompression.
+func WithGzipLevel(level int) Option {
+	return func(o *options) {
+		o.gzipLevel = level
+	}
+}
+
+// WithSeparator sets the separator between path components, for filesystems holding keys such as "HKLM\Software".
+// Paths returned by methods such as Glob and ReadDirPaths use sep. A forward slash is always accepted as a
+// separator in paths passed to the filesystem, as required by fs.FS. The default is the separator of the
+// operating system, see filepath.Separator. An empty separator is ignored.
+func WithSeparator(sep string) Option {
+	return func(o *options) {
+		if sep != "" {
+			o.separator = sep
+		}
+	}
+}
+
+// WithLRU bounds the memory used by the content of files loaded by MountCached to roughly maxBytes. Once the
+// limit is exceeded, the content of the least recently opened mounted files is dropped from memory, to be read
+// from the source again the next time those files are opened. Files written to the filesystem, including mounted
+// files which have been overwritten, are never evicted. A limit of zero or less means unlimited, which is the
+// default.
+func WithLRU(maxBytes int64) Option {
+	return func(o *options) {
+		o.lruBytes = maxBytes
+	}
+}
+
+// WithNoClobber prevents WriteFile and WriteLazyFile from replacing existing files: writing to a path which already
+// exists fails with fs.ErrExist. By default, existing files are overwritten.
+func WithNoClobber() Option {
+	return func(o *options) {
+		o.noClobber = true
+	}
+}
+
+// WithSpill stores the content of files larger than threshold bytes in temporary files within dir (or the default
+// temporary directory if dir is empty), rather than in memory. This is transparent to callers, other than the
+// temporary files needing to be removed with Close once the filesystem is no longer needed.
+func WithSpill(threshold int64, dir string) Option {
+	return func(o *options) {
+		o.spillThreshold = threshold
+		o.spillDir = dir
+	}
+}
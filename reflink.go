@@ -0,0 +1,101 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"time"
+)
+
+// Reflink creates dst as a copy of the regular file src which shares its content rather than copying it, like
+// cp --reflink, so that copying a large file is instantaneous and uses no extra memory. As writes replace the
+// content of a file rather than modifying it in place, the shared content is never changed: writing to either
+// file leaves the other as it was. The copy has the mode of src and the current time as its modification time.
+// If dst exists it is replaced, as WriteFile would, and the other options of the filesystem such as WithNoClobber
+// and WithMaxFiles apply. Symbolic links are followed. Lazy files are written through their LazyOpener, so a copy
+// could not be written independently of src: reflinking one returns an error wrapping fs.ErrInvalid.
+func (m *FS) Reflink(src, dst string) error {
+	f, srcPath, err := m.lookupFile("reflink", src)
+	if err != nil {
+		return err
+	}
+	f.RLock()
+	lazy := f.lazy
+	f.RUnlock()
+	if lazy || !f.stat().Mode().IsRegular() {
+		return &fs.PathError{Op: "reflink", Path: srcPath, Err: fs.ErrInvalid}
+	}
+	path, err := m.realpath("reflink", dst, true)
+	if err != nil {
+		return err
+	}
+	if path == srcPath {
+		return nil
+	}
+	if err := m.checkNameLen("reflink", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("reflink", path); err != nil {
+		return err
+	}
+	if err := m.checkAccess("write", path); err != nil {
+		return err
+	}
+	parentPath, name := splitPath(path, m.dir.sep())
+	parent, err := m.dir.getDir(parentPath)
+	if err != nil {
+		return &fs.PathError{Op: "reflink", Path: path, Err: err}
+	}
+	if err := parent.reflink(name, f); err != nil {
+		return &fs.PathError{Op: "reflink", Path: path, Err: err}
+	}
+	return nil
+}
+
+// reflink adds a clone of f to d with the given name, replacing any existing file
+func (d *dir) reflink(name string, f *file) error {
+	d.populate()
+	now := time.Now()
+	c := f.clone()
+	c.info.name = name
+	c.info.modified = now
+	c.info.created = now
+	c.info.sys = nil
+	c.info.immutable = false
+
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.dirs[name]; ok {
+		c.discard()
+		return fs.ErrExist
+	}
+	previous := fs.ModeIrregular
+	existing, ok := d.files[name]
+	if ok {
+		if d.tree.opts.noClobber {
+			c.discard()
+			return fs.ErrExist
+		}
+		if existing.isImmutable() {
+			c.discard()
+			return ErrImmutable
+		}
+		previous = existing.stat().Mode()
+	}
+	if !ok && !d.tree.reserveEntries(1) {
+		c.discard()
+		return ErrTooManyEntries
+	}
+	if !d.tree.replaceFile(previous, c.info.mode) {
+		if !ok {
+			d.tree.releaseEntries(1)
+		}
+		c.discard()
+		return ErrTooManyFiles
+	}
+	if ok {
+		existing.discard()
+	}
+	d.files[name] = c
+	d.touch(now)
+	d.invalidateSize()
+	return nil
+}
@@ -0,0 +1,108 @@
+package memoryfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Reflink(t *testing.T) {
+	memfs := New()
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	require.NoError(t, memfs.MkdirAll("copies", 0o700))
+	require.NoError(t, memfs.WriteFile("large.bin", large, 0o600))
+
+	dst := strings.ReplaceAll("copies/large.bin", "/", separator)
+	require.NoError(t, memfs.Reflink("large.bin", dst))
+
+	srcInfo, err := memfs.Stat("large.bin")
+	require.NoError(t, err)
+	dstInfo, err := memfs.Stat(dst)
+	require.NoError(t, err)
+	assert.Equal(t, srcInfo.Size(), dstInfo.Size())
+	assert.Equal(t, fs.FileMode(0o600), dstInfo.Mode())
+	assert.Equal(t, "large.bin", dstInfo.Name())
+
+	// the content was shared rather than copied
+	srcContent, err := memfs.Bytes("large.bin")
+	require.NoError(t, err)
+	dstContent, err := memfs.Bytes(dst)
+	require.NoError(t, err)
+	assert.True(t, &srcContent[0] == &dstContent[0])
+
+	require.NoError(t, memfs.WriteFile(dst, []byte("changed"), 0o600))
+	data, err := memfs.ReadFile("large.bin")
+	require.NoError(t, err)
+	assert.Equal(t, large, data)
+	data, err = memfs.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(data))
+
+	w, err := memfs.AppendWriter("large.bin")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("more"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data, err = memfs.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(data))
+
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_ReflinkSpilled(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(8, tmp))
+	defer func() { _ = memfs.Close() }()
+
+	require.NoError(t, memfs.WriteFile("large.txt", []byte("this is larger than eight bytes"), 0o644))
+	require.NoError(t, memfs.Reflink("large.txt", "copy.txt"))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.Remove("large.txt"))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+	data, err := memfs.ReadFile("copy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "this is larger than eight bytes", string(data))
+
+	require.NoError(t, memfs.Remove("copy.txt"))
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+}
+
+func Test_ReflinkInvalid(t *testing.T) {
+	memfs := New(WithMaxFiles(2))
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, memfs.WriteFile("b.txt", []byte("b"), 0o644))
+
+	assert.ErrorIs(t, memfs.Reflink("missing.txt", "c.txt"), fs.ErrNotExist)
+	assert.ErrorIs(t, memfs.Reflink("dir", "c.txt"), ErrIsDir)
+	assert.ErrorIs(t, memfs.Reflink("a.txt", "dir"), fs.ErrExist)
+	assert.ErrorIs(t, memfs.Reflink("a.txt", strings.ReplaceAll("missing/c.txt", "/", separator)), fs.ErrNotExist)
+	assert.ErrorIs(t, memfs.Reflink("a.txt", "c.txt"), ErrTooManyFiles)
+
+	require.NoError(t, memfs.Reflink("a.txt", "b.txt"))
+	data, err := memfs.ReadFile("b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_ReflinkLazy(t *testing.T) {
+	memfs := New()
+	backing := []byte("lazy")
+	require.NoError(t, memfs.WriteLazyFile("lazy.txt", func() (io.Reader, error) {
+		return bytes.NewReader(backing), nil
+	}, 0o644))
+
+	// a copy sharing the opener would write through to the same backing content as the original
+	assert.ErrorIs(t, memfs.Reflink("lazy.txt", "copy.txt"), fs.ErrInvalid)
+	_, err := memfs.Stat("copy.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, memfs.Verify())
+}
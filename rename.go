@@ -0,0 +1,448 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// renameMu serialises renames, which are the only operations that lock two directories at once
+var renameMu sync.Mutex
+
+// Rename renames (moves) oldpath to newpath. If newpath already exists and is not a directory, Rename replaces it
+// atomically: concurrent readers observe either the old or the new file at newpath, but never a missing one.
+// A directory may only replace an empty directory. Renaming a file over a directory, or a directory over a file,
+// fails with an error wrapping ErrIsDir or ErrNotDir respectively.
+// If oldpath is a symbolic link, the link itself is renamed.
+func (m *FS) Rename(oldpath, newpath string) error {
+	if err := m.rename(oldpath, newpath); err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "rename", Path: m.Clean(oldpath), NewPath: m.Clean(newpath)})
+	return nil
+}
+
+func (m *FS) rename(oldpath, newpath string) error {
+	src, err := m.realpath("rename", oldpath, false)
+	if err != nil {
+		return err
+	}
+	dst, err := m.realpath("rename", newpath, false)
+	if err != nil {
+		return err
+	}
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	if src == "" || dst == "" || strings.HasPrefix(dst, src+m.dir.sep()) {
+		return linkErr(fs.ErrInvalid)
+	}
+	if err := m.checkNameLen("rename", dst); err != nil {
+		return linkErr(ErrNameTooLong)
+	}
+	if err := m.checkDepth("rename", dst); err != nil {
+		return linkErr(ErrTooDeep)
+	}
+	// the entry is removed from src and any entry at dst is replaced, so both must be writable
+	for _, path := range []string{src, dst} {
+		if err := m.checkAccess("write", path); err != nil {
+			return err
+		}
+	}
+
+	srcParentPath, srcName := splitPath(src, m.dir.sep())
+	dstParentPath, dstName := splitPath(dst, m.dir.sep())
+	srcParent, err := m.dir.getDir(srcParentPath)
+	if err != nil {
+		return linkErr(err)
+	}
+	dstParent, err := m.dir.getDir(dstParentPath)
+	if err != nil {
+		return linkErr(err)
+	}
+
+	srcParent.populate()
+	dstParent.populate()
+
+	renameMu.Lock()
+	defer renameMu.Unlock()
+	defer lockPair(srcParent, dstParent)()
+
+	if f, ok := srcParent.files[srcName]; ok {
+		if src == dst {
+			return nil
+		}
+		if f.isImmutable() {
+			return linkErr(ErrImmutable)
+		}
+		if _, ok := dstParent.dirs[dstName]; ok {
+			return linkErr(ErrIsDir)
+		}
+		if existing, ok := dstParent.files[dstName]; ok {
+			if existing.isImmutable() {
+				return linkErr(ErrImmutable)
+			}
+			if existing.stat().Mode().IsRegular() {
+				dstParent.tree.releaseFile()
+			}
+			dstParent.tree.releaseEntries(1)
+			existing.discard()
+		}
+		delete(srcParent.files, srcName)
+		f.Lock()
+		f.info.name = dstName
+		f.Unlock()
+		dstParent.files[dstName] = f
+	} else if d, ok := srcParent.dirs[srcName]; ok {
+		if src == dst {
+			return nil
+		}
+		if _, ok := dstParent.files[dstName]; ok {
+			return linkErr(ErrNotDir)
+		}
+		if existing, ok := dstParent.dirs[dstName]; ok {
+			existing.RLock()
+			empty := len(existing.dirs) == 0 && len(existing.files) == 0
+			existing.RUnlock()
+			if !empty {
+				return linkErr(fs.ErrExist)
+			}
+			dstParent.tree.releaseEntries(1)
+		}
+		delete(srcParent.dirs, srcName)
+		d.Lock()
+		d.info.name = dstName
+		d.Unlock()
+		d.setParent(dstParent)
+		dstParent.dirs[dstName] = d
+	} else {
+		return linkErr(fs.ErrNotExist)
+	}
+
+	now := time.Now()
+	srcParent.touch(now)
+	dstParent.touch(now)
+	srcParent.invalidateSize()
+	dstParent.invalidateSize()
+	return nil
+}
+
+// lockPair locks two directories for writing, which may be the same directory, and returns a function which
+// unlocks them. It must be called with renameMu held.
+func lockPair(a, b *dir) (unlock func()) {
+	// always lock top-down, in the same order as writers descending the tree
+	first, second := a, b
+	if b.isAncestorOf(a) {
+		first, second = b, a
+	}
+	first.Lock()
+	if second == first {
+		return first.Unlock
+	}
+	second.Lock()
+	return func() {
+		second.Unlock()
+		first.Unlock()
+	}
+}
+
+// SwapFiles atomically exchanges the files at paths a and b, including their content and metadata, so that
+// concurrent readers of either path observe one complete file or the other, but never a missing one. Handles
+// opened before the swap continue to read the file they opened, which is now at the other path. Both paths must
+// be existing regular files: otherwise an error wrapping fs.ErrNotExist, ErrIsDir or fs.ErrInvalid is returned.
+// Symbolic links are followed.
+func (m *FS) SwapFiles(a, b string) error {
+	pathA, err := m.realpath("swap", a, true)
+	if err != nil {
+		return err
+	}
+	pathB, err := m.realpath("swap", b, true)
+	if err != nil {
+		return err
+	}
+	// both files are replaced, so both must be writable
+	for _, path := range []string{pathA, pathB} {
+		if err := m.checkAccess("write", path); err != nil {
+			return err
+		}
+	}
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "swap", Old: a, New: b, Err: err}
+	}
+
+	parentPathA, nameA := splitPath(pathA, m.dir.sep())
+	parentPathB, nameB := splitPath(pathB, m.dir.sep())
+	parentA, err := m.dir.getDir(parentPathA)
+	if err != nil {
+		return linkErr(err)
+	}
+	parentB, err := m.dir.getDir(parentPathB)
+	if err != nil {
+		return linkErr(err)
+	}
+
+	parentA.populate()
+	parentB.populate()
+
+	renameMu.Lock()
+	defer renameMu.Unlock()
+	defer lockPair(parentA, parentB)()
+
+	lookup := func(parent *dir, name string) (*file, error) {
+		f, ok := parent.files[name]
+		if !ok {
+			if _, ok := parent.dirs[name]; ok {
+				return nil, ErrIsDir
+			}
+			return nil, fs.ErrNotExist
+		}
+		if !f.stat().Mode().IsRegular() {
+			return nil, fs.ErrInvalid
+		}
+		if f.isImmutable() {
+			return nil, ErrImmutable
+		}
+		return f, nil
+	}
+	fileA, err := lookup(parentA, nameA)
+	if err != nil {
+		return linkErr(err)
+	}
+	fileB, err := lookup(parentB, nameB)
+	if err != nil {
+		return linkErr(err)
+	}
+	if fileA == fileB {
+		return nil
+	}
+
+	fileA.Lock()
+	fileA.info.name = nameB
+	fileA.Unlock()
+	fileB.Lock()
+	fileB.info.name = nameA
+	fileB.Unlock()
+	parentA.files[nameA], parentB.files[nameB] = fileB, fileA
+
+	now := time.Now()
+	parentA.touch(now)
+	parentB.touch(now)
+	parentA.invalidateSize()
+	parentB.invalidateSize()
+	return nil
+}
+
+// renameMove is a single entry moved by RenameFunc
+type renameMove struct {
+	from, to string
+	entry    trashEntry
+}
+
+// RenameFunc moves every entry beneath root to the path returned by transform, which is called with the path of
+// each file, symbolic link and empty directory, in the same form as given to Stat. Entries for which transform
+// returns the same path are left where they are. Parent directories are created as needed, taking the mode and
+// times of the directories they correspond to, and directories left empty by the moves are removed.
+//
+// All of the new paths are checked before anything is moved: if two entries would be moved to the same path, or an
+// entry would be moved onto or beneath an existing file that is not itself being moved, an error wrapping
+// fs.ErrExist or ErrNotDir respectively is returned and the filesystem is left unchanged. If a move then fails,
+// for example because of the limit set with WithMaxEntries, the entries already moved are put back and the
+// directories created for them removed. RenameFunc is serialised with Rename, but is not atomic with respect to
+// other writers.
+func (m *FS) RenameFunc(root string, transform func(path string) string) error {
+	d, rootPath, err := m.walkRoot("renamefunc", root)
+	if err != nil {
+		return err
+	}
+	sep := m.dir.sep()
+
+	var moves []renameMove
+	_ = d.walk("", func(path string, sub *dir, f *file) error {
+		if f == nil {
+			if path == "" {
+				return nil
+			}
+			sub.RLock()
+			empty := len(sub.dirs) == 0 && len(sub.files) == 0
+			sub.RUnlock()
+			if !empty {
+				return nil
+			}
+		}
+		from := joinPath(rootPath, path, sep)
+		if to := m.Clean(transform(from)); to != from {
+			moves = append(moves, renameMove{from: from, to: to})
+		}
+		return nil
+	})
+
+	renameMu.Lock()
+	defer renameMu.Unlock()
+
+	if err := m.checkRenameMoves(moves); err != nil {
+		return err
+	}
+	infos := m.renameDirInfos(rootPath, moves)
+
+	for i := range moves {
+		entry, err := m.detach(moves[i].from, false)
+		if err != nil {
+			m.undoRenameMoves(moves[:i], false)
+			return &os.LinkError{Op: "renamefunc", Old: moves[i].from, New: moves[i].to, Err: err}
+		}
+		moves[i].entry = entry
+	}
+	var created []string
+	for i, move := range moves {
+		parent, _ := splitPath(move.to, sep)
+		missing := m.missingDirs(parent)
+		err := m.dir.MkdirAll(parent, m.implicitDirPerm(parent, defaultDirPerm))
+		if err == nil {
+			created = append(created, missing...)
+			err = m.attach(move.to, move.entry)
+		}
+		if err != nil {
+			m.undoRenameMoves(moves[:i], true)
+			m.undoRenameMoves(moves[i:], false)
+			m.removeDirs(created)
+			return &os.LinkError{Op: "renamefunc", Old: move.from, New: move.to, Err: err}
+		}
+	}
+
+	// remove the directories emptied by the moves, deepest first
+	emptied := map[string]struct{}{}
+	for _, move := range moves {
+		for parent, _ := splitPath(move.from, sep); len(parent) > len(rootPath); parent, _ = splitPath(parent, sep) {
+			emptied[parent] = struct{}{}
+		}
+	}
+	parents := make([]string, 0, len(emptied))
+	for parent := range emptied {
+		parents = append(parents, parent)
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return strings.Count(parents[i], sep) > strings.Count(parents[j], sep)
+	})
+	for _, parent := range parents {
+		_ = m.dir.removePath(parent, false)
+	}
+
+	// directories created for the new paths take the metadata of those they replace, once nothing more will touch
+	// them
+	for _, path := range created {
+		info, ok := infos[path]
+		if !ok {
+			continue
+		}
+		if target, err := m.dir.getDir(path); err == nil {
+			target.Lock()
+			target.info.mode = info.mode
+			target.info.modified = info.modified
+			target.info.created = info.created
+			target.Unlock()
+		}
+	}
+	return nil
+}
+
+// renameDirInfos returns the metadata to give each directory which may be created by RenameFunc, keyed by its new
+// path. The parent of the new path of each entry corresponds to the parent of its old path, and so on upwards
+// until either the root of the walk or the root of the filesystem is reached. Where several directories
+// correspond to the same new path, the first moved wins.
+func (m *FS) renameDirInfos(rootPath string, moves []renameMove) map[string]fileinfo {
+	sep := m.dir.sep()
+	infos := map[string]fileinfo{}
+	for _, move := range moves {
+		from, to := move.from, move.to
+		for {
+			from, _ = splitPath(from, sep)
+			to, _ = splitPath(to, sep)
+			if to == "" || len(from) <= len(rootPath) {
+				break
+			}
+			if _, ok := infos[to]; ok {
+				continue
+			}
+			if d, err := m.dir.getDir(from); err == nil {
+				d.RLock()
+				infos[to] = d.info
+				d.RUnlock()
+			}
+		}
+	}
+	return infos
+}
+
+// checkRenameMoves returns an error if any of the moves would collide with each other or with an entry which is not
+// being moved, or would modify an immutable file
+func (m *FS) checkRenameMoves(moves []renameMove) error {
+	sep := m.dir.sep()
+	moving := make(map[string]struct{}, len(moves))
+	for _, move := range moves {
+		moving[move.from] = struct{}{}
+	}
+	targets := make(map[string]string, len(moves))
+	for _, move := range moves {
+		linkErr := func(err error) error {
+			return &os.LinkError{Op: "renamefunc", Old: move.from, New: move.to, Err: err}
+		}
+		if move.to == "" || strings.HasPrefix(move.to, move.from+sep) {
+			return linkErr(fs.ErrInvalid)
+		}
+		if err := m.checkNameLen("renamefunc", move.to); err != nil {
+			return linkErr(ErrNameTooLong)
+		}
+		if err := m.checkDepth("renamefunc", move.to); err != nil {
+			return err
+		}
+		if f, err := m.dir.getFile(move.from); err == nil && f.isImmutable() {
+			return linkErr(ErrImmutable)
+		}
+		if _, ok := targets[move.to]; ok {
+			return linkErr(fs.ErrExist)
+		}
+		targets[move.to] = move.from
+		if _, ok := moving[move.to]; !ok {
+			if _, err := m.dir.getFile(move.to); err == nil {
+				return linkErr(fs.ErrExist)
+			}
+			if _, err := m.dir.getDir(move.to); err == nil {
+				return linkErr(fs.ErrExist)
+			}
+		}
+		for parent, _ := splitPath(move.to, sep); parent != ""; parent, _ = splitPath(parent, sep) {
+			if _, ok := moving[parent]; ok {
+				continue
+			}
+			if _, err := m.dir.getFile(parent); err == nil {
+				return linkErr(ErrNotDir)
+			}
+		}
+	}
+	// an entry cannot be moved beneath the new path of another
+	for to, from := range targets {
+		for parent, _ := splitPath(to, sep); parent != ""; parent, _ = splitPath(parent, sep) {
+			if _, ok := targets[parent]; ok {
+				return &os.LinkError{Op: "renamefunc", Old: from, New: to, Err: ErrNotDir}
+			}
+		}
+	}
+	return nil
+}
+
+// undoRenameMoves returns detached entries to their original paths, first detaching them from their new paths if
+// they have already been attached there
+func (m *FS) undoRenameMoves(moves []renameMove, attached bool) {
+	for i := len(moves) - 1; i >= 0; i-- {
+		if attached {
+			_, _ = m.detach(moves[i].to, false)
+		}
+		_ = m.attach(moves[i].from, moves[i].entry)
+	}
+}
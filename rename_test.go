@@ -0,0 +1,380 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Rename(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.MkdirAll("c", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("hello"), 0o644))
+
+	t.Run("File to another directory", func(t *testing.T) {
+		require.NoError(t, memfs.Rename("a/b/file.txt", "c/moved.txt"))
+		_, err := memfs.Stat("a/b/file.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		info, err := memfs.Stat("c/moved.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "moved.txt", info.Name())
+		data, err := memfs.ReadFile("c/moved.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		require.NoError(t, memfs.Rename("a", "c/a"))
+		info, err := memfs.Stat("c/a/b")
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+		_, err = memfs.Stat("a")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		size, err := memfs.DirSize("c")
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), size)
+	})
+
+	t.Run("Overwrites existing file", func(t *testing.T) {
+		require.NoError(t, memfs.WriteFile("old.txt", []byte("old"), 0o644))
+		require.NoError(t, memfs.WriteFile("new.txt", []byte("new"), 0o644))
+		require.NoError(t, memfs.Rename("new.txt", "old.txt"))
+		data, err := memfs.ReadFile("old.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+		_, err = memfs.Stat("new.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Type mismatch", func(t *testing.T) {
+		assert.ErrorIs(t, memfs.Rename("old.txt", "c"), syscall.EISDIR)
+		assert.ErrorIs(t, memfs.Rename("c", "old.txt"), syscall.ENOTDIR)
+	})
+
+	t.Run("Into own subtree", func(t *testing.T) {
+		assert.ErrorIs(t, memfs.Rename("c", "c/a/b/c"), fs.ErrInvalid)
+	})
+
+	t.Run("Missing source", func(t *testing.T) {
+		assert.ErrorIs(t, memfs.Rename("missing", "other"), fs.ErrNotExist)
+	})
+}
+
+func Test_RenameOverFileBeingRead(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("target.txt", []byte("version 0"), 0o644))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var readErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := memfs.ReadFile("target.txt"); err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+
+	for i := 1; i <= 500; i++ {
+		require.NoError(t, memfs.WriteFile("tmp.txt", []byte(fmt.Sprintf("version %d", i)), 0o644))
+		require.NoError(t, memfs.Rename("tmp.txt", "target.txt"))
+	}
+	close(done)
+	wg.Wait()
+
+	require.NoError(t, readErr)
+	data, err := memfs.ReadFile("target.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "version 500", string(data))
+}
+
+func Test_RenameFuncStripPrefix(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("import/root/a/b", "/", separator), 0o700))
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("import/root/empty", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("import/root/top.txt", "/", separator), []byte("top"), 0o644))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("import/root/a/b/deep.txt", "/", separator), []byte("deep"), 0o644))
+	require.NoError(t, memfs.Symlink("top.txt", strings.ReplaceAll("import/root/link", "/", separator)))
+
+	prefix := strings.ReplaceAll("import/root/", "/", separator)
+	require.NoError(t, memfs.RenameFunc("import", func(path string) string {
+		return strings.TrimPrefix(path, prefix)
+	}))
+
+	for path, expected := range map[string]string{
+		"top.txt":      "top",
+		"a/b/deep.txt": "deep",
+		"link":         "top",
+	} {
+		data, err := memfs.ReadFile(strings.ReplaceAll(path, "/", separator))
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, string(data), path)
+	}
+	info, err := memfs.Stat("empty")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = memfs.Stat(strings.ReplaceAll("import/root", "/", separator))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	info, err = memfs.Stat("import")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&memfs.dir.tree.files))
+}
+
+func Test_RenameFuncLowercase(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("Docs/Guides", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("Docs/Guides/README.md", "/", separator), []byte("readme"), 0o644))
+	require.NoError(t, memfs.WriteFile("lower.txt", []byte("lower"), 0o644))
+
+	require.NoError(t, memfs.RenameFunc(".", strings.ToLower))
+
+	data, err := memfs.ReadFile(strings.ReplaceAll("docs/guides/readme.md", "/", separator))
+	require.NoError(t, err)
+	assert.Equal(t, "readme", string(data))
+	data, err = memfs.ReadFile("lower.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "lower", string(data))
+	_, err = memfs.Stat("Docs")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_RenameFuncCollision(t *testing.T) {
+	setup := func(t *testing.T) *FS {
+		memfs := New()
+		require.NoError(t, memfs.MkdirAll("a", 0o700))
+		require.NoError(t, memfs.MkdirAll("b", 0o700))
+		require.NoError(t, memfs.WriteFile(strings.ReplaceAll("a/file.txt", "/", separator), []byte("a"), 0o644))
+		require.NoError(t, memfs.WriteFile(strings.ReplaceAll("b/file.txt", "/", separator), []byte("b"), 0o644))
+		require.NoError(t, memfs.WriteFile("file.txt", []byte("root"), 0o644))
+		return memfs
+	}
+	unchanged := func(t *testing.T, memfs *FS) {
+		for path, expected := range map[string]string{
+			"a/file.txt": "a",
+			"b/file.txt": "b",
+			"file.txt":   "root",
+		} {
+			data, err := memfs.ReadFile(strings.ReplaceAll(path, "/", separator))
+			require.NoError(t, err, path)
+			assert.Equal(t, expected, string(data), path)
+		}
+	}
+
+	t.Run("With each other", func(t *testing.T) {
+		memfs := setup(t)
+		err := memfs.RenameFunc(".", func(path string) string {
+			if strings.HasSuffix(path, separator+"file.txt") {
+				return "merged.txt"
+			}
+			return path
+		})
+		assert.ErrorIs(t, err, fs.ErrExist)
+		unchanged(t, memfs)
+		_, err = memfs.Stat("merged.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("With an existing file", func(t *testing.T) {
+		memfs := setup(t)
+		err := memfs.RenameFunc("a", func(path string) string {
+			return "file.txt"
+		})
+		assert.ErrorIs(t, err, fs.ErrExist)
+		unchanged(t, memfs)
+	})
+
+	t.Run("With an existing directory", func(t *testing.T) {
+		memfs := setup(t)
+		err := memfs.RenameFunc("a", func(path string) string {
+			return "b"
+		})
+		assert.ErrorIs(t, err, fs.ErrExist)
+		unchanged(t, memfs)
+	})
+
+	t.Run("Beneath an existing file", func(t *testing.T) {
+		memfs := setup(t)
+		err := memfs.RenameFunc("a", func(path string) string {
+			return strings.ReplaceAll("file.txt/nested.txt", "/", separator)
+		})
+		assert.ErrorIs(t, err, syscall.ENOTDIR)
+		unchanged(t, memfs)
+	})
+
+	t.Run("Swapping paths", func(t *testing.T) {
+		memfs := setup(t)
+		a, b := strings.ReplaceAll("a/file.txt", "/", separator), strings.ReplaceAll("b/file.txt", "/", separator)
+		require.NoError(t, memfs.RenameFunc(".", func(path string) string {
+			switch path {
+			case a:
+				return b
+			case b:
+				return a
+			}
+			return path
+		}))
+		data, err := memfs.ReadFile(a)
+		require.NoError(t, err)
+		assert.Equal(t, "b", string(data))
+		data, err = memfs.ReadFile(b)
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(data))
+	})
+}
+
+func Test_SwapFiles(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFileAt("a.txt", []byte("first"), 0o600, modified))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("dir/b.txt", "/", separator), []byte("second file"), 0o644))
+	require.NoError(t, memfs.Symlink("a.txt", "link"))
+
+	require.NoError(t, memfs.SwapFiles("a.txt", strings.ReplaceAll("dir/b.txt", "/", separator)))
+
+	data, err := memfs.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "second file", string(data))
+	info, err := memfs.Stat("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", info.Name())
+	assert.Equal(t, fs.FileMode(0o644), info.Mode())
+
+	info, err = memfs.Stat(strings.ReplaceAll("dir/b.txt", "/", separator))
+	require.NoError(t, err)
+	assert.Equal(t, "b.txt", info.Name())
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	size, err := memfs.DirSize("dir")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), size)
+
+	require.NoError(t, memfs.SwapFiles("link", strings.ReplaceAll("dir/b.txt", "/", separator)))
+	data, err = memfs.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+	require.NoError(t, memfs.Verify())
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.ErrorIs(t, memfs.SwapFiles("a.txt", "missing.txt"), fs.ErrNotExist)
+		assert.ErrorIs(t, memfs.SwapFiles("missing.txt", "a.txt"), fs.ErrNotExist)
+		assert.ErrorIs(t, memfs.SwapFiles("a.txt", "dir"), syscall.EISDIR)
+		require.NoError(t, memfs.SwapFiles("a.txt", "a.txt"))
+	})
+}
+
+func Test_SwapFilesAccessChecker(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("x", []byte("x"), 0o644))
+	require.NoError(t, memfs.WriteFile("protected", []byte("protected"), 0o644))
+	require.NoError(t, memfs.WriteFile("y", []byte("y"), 0o644))
+
+	memfs.SetAccessChecker(func(op string, info fs.FileInfo) error {
+		if op == "write" && info.Name() == "protected" {
+			return fs.ErrPermission
+		}
+		return nil
+	})
+	assert.ErrorIs(t, memfs.SwapFiles("x", "protected"), fs.ErrPermission)
+	assert.ErrorIs(t, memfs.SwapFiles("protected", "x"), fs.ErrPermission)
+	data, err := memfs.ReadFile("protected")
+	require.NoError(t, err)
+	assert.Equal(t, "protected", string(data))
+
+	require.NoError(t, memfs.SwapFiles("x", "y"))
+	data, err = memfs.ReadFile("x")
+	require.NoError(t, err)
+	assert.Equal(t, "y", string(data))
+}
+
+func Test_SwapFilesWhileReading(t *testing.T) {
+	memfs := New()
+	versions := []string{"old content", "new content, which is longer"}
+	require.NoError(t, memfs.WriteFile("a.txt", []byte(versions[0]), 0o644))
+	require.NoError(t, memfs.WriteFile("b.txt", []byte(versions[1]), 0o644))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var readErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := memfs.ReadFile("a.txt")
+			if err != nil {
+				readErr = err
+				return
+			}
+			if string(data) != versions[0] && string(data) != versions[1] {
+				readErr = fmt.Errorf("read mixed content %q", data)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 500; i++ {
+		require.NoError(t, memfs.SwapFiles("a.txt", "b.txt"))
+	}
+	close(done)
+	wg.Wait()
+
+	require.NoError(t, readErr)
+	data, err := memfs.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, versions[0], string(data))
+}
+
+func Test_RenameFuncKeepsDirectoryInfo(t *testing.T) {
+	modified := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("import/a", "/", separator), 0o750))
+	require.NoError(t, memfs.MkdirAll(strings.ReplaceAll("import/a/b", "/", separator), 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("import/a/b/file.txt", "/", separator), []byte("hello"), 0o644))
+	require.NoError(t, memfs.SetModified(strings.ReplaceAll("import/a", "/", separator), modified))
+	require.NoError(t, memfs.SetModified(strings.ReplaceAll("import/a/b", "/", separator), modified.Add(time.Hour)))
+
+	prefix := "import" + separator
+	require.NoError(t, memfs.RenameFunc("import", func(path string) string {
+		return strings.TrimPrefix(path, prefix)
+	}))
+
+	info, err := memfs.Stat("a")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o750, info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	info, err = memfs.Stat(strings.ReplaceAll("a/b", "/", separator))
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o700, info.Mode())
+	assert.True(t, modified.Add(time.Hour).Equal(info.ModTime()))
+
+	_, err = memfs.Stat(strings.ReplaceAll("import/a", "/", separator))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,107 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Event describes a single change to a filesystem, carrying everything needed to apply it to another, see Replay
+// and WithEventLog
+type Event struct {
+	Op      string      // "write", "mkdir", "remove", "removeall", "rename" or "symlink"
+	Path    string      // the path changed, or the old path for "rename"
+	NewPath string      // the new path for "rename"
+	Target  string      // the link target for "symlink"
+	Data    []byte      // the complete content of the file for "write"
+	Mode    fs.FileMode // the permissions for "write" and "mkdir"
+	ModTime time.Time   // the modification time for "write", or the time of the replay if zero
+}
+
+// eventLog is the ordered record of changes kept by a tree
+type eventLog struct {
+	sync.Mutex
+	events []Event
+}
+
+// WithEventLog records an Event for every successful call which changes the filesystem in a way an Event can
+// describe, in order, so that the changes can be applied to another filesystem with Replay. The record is
+// returned by Events. WriteFile, WriteFileAt, WriteFileDeep, WriteReader and AppendWriter are recorded as "write"
+// events holding the whole content of the file, and MkdirAll, Remove, RemoveAll, Rename and Symlink as the events
+// of the same name. Other changes, such as WriteLazyFile, SetModified or SwapFiles, are not recorded.
+func WithEventLog() Option {
+	return func(o *options) {
+		o.eventLog = true
+	}
+}
+
+// Events returns a copy of the changes recorded since the filesystem was created or ResetEvents was last called.
+// It is always empty unless the filesystem was created with WithEventLog.
+func (m *FS) Events() []Event {
+	log := &m.dir.tree.events
+	log.Lock()
+	defer log.Unlock()
+	events := make([]Event, len(log.events))
+	copy(events, log.events)
+	return events
+}
+
+// ResetEvents discards the changes recorded so far.
+func (m *FS) ResetEvents() {
+	log := &m.dir.tree.events
+	log.Lock()
+	defer log.Unlock()
+	log.events = nil
+}
+
+// recordEvent appends event to the event log if the filesystem was created WithEventLog. Data is copied, as the
+// caller may modify it once the write returns.
+func (m *FS) recordEvent(event Event) {
+	if !m.dir.tree.opts.eventLog {
+		return
+	}
+	if event.Data != nil {
+		event.Data = append([]byte(nil), event.Data...)
+	}
+	log := &m.dir.tree.events
+	log.Lock()
+	defer log.Unlock()
+	log.events = append(log.events, event)
+}
+
+// Replay applies events to the filesystem in order, stopping at the first which fails. Each event is applied with
+// the method it names, so "write" replaces the whole file as WriteFile does and "mkdir" creates any missing
+// parents as MkdirAll does. The returned error identifies the failed event by its index. An event with an unknown
+// Op fails with an error wrapping fs.ErrInvalid.
+func (m *FS) Replay(events []Event) error {
+	for i, event := range events {
+		if err := m.replay(event); err != nil {
+			return fmt.Errorf("failed to replay event %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (m *FS) replay(event Event) error {
+	switch event.Op {
+	case "write":
+		modified := event.ModTime
+		if modified.IsZero() {
+			modified = time.Now()
+		}
+		return m.WriteFileAt(event.Path, event.Data, event.Mode, modified)
+	case "mkdir":
+		return m.MkdirAll(event.Path, event.Mode)
+	case "remove":
+		return m.Remove(event.Path)
+	case "removeall":
+		return m.RemoveAll(event.Path)
+	case "rename":
+		return m.Rename(event.Path, event.NewPath)
+	case "symlink":
+		return m.Symlink(event.Target, event.Path)
+	default:
+		return &fs.PathError{Op: event.Op, Path: event.Path, Err: fs.ErrInvalid}
+	}
+}
@@ -0,0 +1,156 @@
+package memoryfs
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Replay(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []Event{
+		{Op: "mkdir", Path: "a/b", Mode: 0o755},
+		{Op: "write", Path: "a/b/file.txt", Data: []byte("first"), Mode: 0o644, ModTime: modified},
+		{Op: "write", Path: "a/b/other.txt", Data: []byte("other"), Mode: 0o600},
+		{Op: "write", Path: "a/b/file.txt", Data: []byte("second"), Mode: 0o644, ModTime: modified},
+		{Op: "rename", Path: "a/b/file.txt", NewPath: "a/file.txt"},
+		{Op: "symlink", Path: "a/link", Target: "file.txt"},
+		{Op: "remove", Path: "a/b/other.txt"},
+	}
+
+	memfs := New()
+	require.NoError(t, memfs.Replay(events))
+
+	data, err := memfs.ReadFile("a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	info, err := memfs.Stat("a/file.txt")
+	require.NoError(t, err)
+	assert.True(t, modified.Equal(info.ModTime()))
+	entries, err := memfs.ReadDir("a/b")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, memfs.Replay([]Event{{Op: "removeall", Path: "a"}}))
+	_, err = memfs.Stat("a")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ReplayStopsAtFirstError(t *testing.T) {
+	memfs := New()
+	err := memfs.Replay([]Event{
+		{Op: "write", Path: "first.txt", Mode: 0o644},
+		{Op: "remove", Path: "missing.txt"},
+		{Op: "write", Path: "second.txt", Mode: 0o644},
+	})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Contains(t, err.Error(), "event 1")
+
+	_, err = memfs.Stat("first.txt")
+	assert.NoError(t, err)
+	_, err = memfs.Stat("second.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	assert.ErrorIs(t, memfs.Replay([]Event{{Op: "chmod", Path: "first.txt"}}), fs.ErrInvalid)
+}
+
+func Test_EventLogReplay(t *testing.T) {
+	recorded := New(WithEventLog())
+	require.NoError(t, recorded.MkdirAll(strings.ReplaceAll("a/b", "/", separator), 0o750))
+	require.NoError(t, recorded.WriteFile(strings.ReplaceAll("a/b/file.txt", "/", separator), []byte("first"), 0o644))
+	require.NoError(t, recorded.WriteFileDeep(strings.ReplaceAll("c/d/deep.txt", "/", separator), []byte("deep"), 0o600))
+	require.NoError(t, recorded.WriteReader("reader.txt", strings.NewReader("reader"), 0o644))
+	w, err := recorded.AppendWriter("log.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("appended"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, recorded.Rename(strings.ReplaceAll("a/b/file.txt", "/", separator), strings.ReplaceAll("a/file.txt", "/", separator)))
+	require.NoError(t, recorded.Symlink("file.txt", strings.ReplaceAll("a/link", "/", separator)))
+	require.NoError(t, recorded.Remove("reader.txt"))
+	require.NoError(t, recorded.RemoveAll("c"))
+
+	// failed calls are not recorded
+	assert.Error(t, recorded.Remove("missing.txt"))
+
+	events := recorded.Events()
+	assert.Len(t, events, 11)
+	assert.Equal(t, "mkdir", events[0].Op)
+	assert.Equal(t, "removeall", events[len(events)-1].Op)
+
+	replayed := New()
+	require.NoError(t, replayed.Replay(events))
+	diff, err := diffDirs("", recorded.dir, replayed.dir)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+	assert.Equal(t, recorded.Fingerprint(), replayed.Fingerprint())
+
+	info, err := replayed.Stat("log.txt")
+	require.NoError(t, err)
+	expected, err := recorded.Stat("log.txt")
+	require.NoError(t, err)
+	assert.True(t, expected.ModTime().Equal(info.ModTime()))
+
+	recorded.ResetEvents()
+	assert.Empty(t, recorded.Events())
+	assert.Empty(t, replayed.Events())
+}
+
+func Test_EventLogTransactions(t *testing.T) {
+	memfs := New(WithEventLog())
+	require.NoError(t, memfs.MkdirAll("src", 0o755))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("src/keep.txt", "/", separator), []byte("keep"), 0o644))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("src/old.txt", "/", separator), []byte("old"), 0o644))
+
+	require.NoError(t, memfs.Transaction(func(tx *Tx) error {
+		if err := tx.Remove(strings.ReplaceAll("src/keep.txt", "/", separator)); err != nil {
+			return err
+		}
+		if err := tx.Mkdir(strings.ReplaceAll("src/dir", "/", separator), 0o700); err != nil {
+			return err
+		}
+		if err := tx.Rename(strings.ReplaceAll("src/old.txt", "/", separator), strings.ReplaceAll("src/dir/old.txt", "/", separator)); err != nil {
+			return err
+		}
+		return tx.WriteFile(strings.ReplaceAll("src/committed.txt", "/", separator), []byte("committed"), 0o600)
+	}))
+	errRollback := errors.New("rollback")
+	assert.ErrorIs(t, memfs.Transaction(func(tx *Tx) error {
+		if err := tx.WriteFile(strings.ReplaceAll("src/new.txt", "/", separator), []byte("new"), 0o644); err != nil {
+			return err
+		}
+		if err := tx.Remove(strings.ReplaceAll("src/committed.txt", "/", separator)); err != nil {
+			return err
+		}
+		if err := tx.Rename(strings.ReplaceAll("src/dir/old.txt", "/", separator), strings.ReplaceAll("src/moved.txt", "/", separator)); err != nil {
+			return err
+		}
+		return errRollback
+	}), errRollback)
+
+	// replaying the recorded events into a second directory reproduces the first
+	events := memfs.Events()
+	for i := range events {
+		events[i].Path = "dst" + strings.TrimPrefix(events[i].Path, "src")
+		if events[i].NewPath != "" {
+			events[i].NewPath = "dst" + strings.TrimPrefix(events[i].NewPath, "src")
+		}
+	}
+	require.NoError(t, memfs.Replay(events))
+	equal, err := memfs.SubtreeEqual("src", "dst")
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	paths, err := memfs.ReadDirDepth("dst", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		strings.ReplaceAll("dst/committed.txt", "/", separator),
+		strings.ReplaceAll("dst/dir", "/", separator),
+		strings.ReplaceAll("dst/dir/old.txt", "/", separator),
+	}, paths)
+}
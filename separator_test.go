@@ -0,0 +1,66 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CustomSeparator(t *testing.T) {
+	memfs := New(WithSeparator(`\`))
+	require.NoError(t, memfs.MkdirAll(`HKLM\Software\Vendor`, 0o700))
+	require.NoError(t, memfs.WriteFile(`HKLM\Software\Vendor\Key`, []byte("value"), 0o644))
+
+	data, err := memfs.ReadFile(`HKLM\Software\Vendor\Key`)
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(data))
+
+	data, err = fs.ReadFile(memfs, "HKLM/Software/Vendor/Key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(data))
+
+	entries, err := memfs.ReadDir(`HKLM\Software`)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Vendor", entries[0].Name())
+
+	paths, err := memfs.ReadDirPaths(`HKLM\Software`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`HKLM\Software\Vendor`}, paths)
+
+	matches, err := memfs.Glob(`HKLM\*\Vendor\*`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`HKLM\Software\Vendor\Key`}, matches)
+
+	assert.Equal(t, `HKLM\Software`, memfs.Clean(`\HKLM\.\Software\Vendor\..`))
+
+	rel, err := memfs.Rel(`HKLM`, `HKLM\Software\Vendor`)
+	require.NoError(t, err)
+	assert.Equal(t, `Software\Vendor`, rel)
+
+	require.NoError(t, memfs.Rename(`HKLM\Software\Vendor\Key`, `HKLM\Key`))
+	_, err = memfs.Stat(`HKLM\Key`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_CustomSeparatorMultiByte(t *testing.T) {
+	memfs := New(WithSeparator("::"))
+	require.NoError(t, memfs.WriteFileDeep("a::b::c", []byte("c"), 0o644))
+
+	paths, err := memfs.ReadDirPaths("a::b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a::b::c"}, paths)
+
+	groups, err := memfs.SortedByMTime("a", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a::b::c"}, groups)
+
+	require.NoError(t, memfs.Symlink("b::c", "a::link"))
+	data, err := memfs.ReadFile("a::link")
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(data))
+}
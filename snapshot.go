@@ -0,0 +1,62 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"sync/atomic"
+)
+
+// Snapshot returns a read-only view of the filesystem as it was at the time of the call. Later writes to m are
+// not reflected in the snapshot. Taking a snapshot is O(entries): the directory structure is copied, while file
+// content is shared with m rather than copied. Each directory is only locked briefly while it is copied, so writers
+// are not blocked for long, but renames wait until the copy is complete so that an entry moved between directories
+// concurrently appears in the snapshot exactly once. Each write made concurrently with Snapshot is either fully
+// included or excluded.
+func (m *FS) Snapshot() fs.FS {
+	// a rename between two directories copied at different times could otherwise be seen in both or neither
+	renameMu.Lock()
+	defer renameMu.Unlock()
+
+	t := &tree{
+		opts:    m.dir.tree.opts,
+		files:   atomic.LoadInt64(&m.dir.tree.files),
+		entries: atomic.LoadInt64(&m.dir.tree.entries),
+	}
+	return &readOnlyFS{
+		fs: &FS{
+			dir: m.dir.clone(t, nil),
+		},
+	}
+}
+
+// readOnlyFS exposes only the read operations of an FS
+type readOnlyFS struct {
+	fs *FS
+}
+
+func (r *readOnlyFS) Open(name string) (fs.File, error) {
+	return r.fs.Open(name)
+}
+
+func (r *readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	return r.fs.Stat(name)
+}
+
+func (r *readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return r.fs.ReadDir(name)
+}
+
+func (r *readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return r.fs.ReadFile(name)
+}
+
+func (r *readOnlyFS) Glob(pattern string) ([]string, error) {
+	return r.fs.Glob(pattern)
+}
+
+func (r *readOnlyFS) Sub(dir string) (fs.FS, error) {
+	sub, err := r.fs.Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFS{fs: sub.(*FS)}, nil
+}
@@ -0,0 +1,117 @@
+package memoryfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Snapshot(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("original"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/removed.txt", []byte("removed later"), 0o644))
+
+	snapshot := memfs.Snapshot()
+
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("modified"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/new.txt", []byte("new"), 0o644))
+	require.NoError(t, memfs.Remove("a/removed.txt"))
+
+	data, err := fs.ReadFile(snapshot, "a/b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+
+	data, err = fs.ReadFile(snapshot, "a/removed.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "removed later", string(data))
+
+	_, err = fs.Stat(snapshot, "a/new.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err = memfs.ReadFile("a/b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "modified", string(data))
+
+	_, ok := snapshot.(interface {
+		WriteFile(string, []byte, fs.FileMode) error
+	})
+	assert.False(t, ok, "snapshot should not be writable")
+
+	sub, err := fs.Sub(snapshot, "a")
+	require.NoError(t, err)
+	data, err = fs.ReadFile(sub, "b/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+}
+
+func Test_SnapshotWhileWriting(t *testing.T) {
+	memfs := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.NoError(t, memfs.WriteFile(fmt.Sprintf("file_%d_%d.txt", i, j), []byte("hello"), 0o644))
+			}
+		}(i)
+	}
+
+	for i := 0; i < 20; i++ {
+		snapshot := memfs.Snapshot()
+		entries, err := fs.ReadDir(snapshot, ".")
+		require.NoError(t, err)
+		for _, entry := range entries {
+			data, err := fs.ReadFile(snapshot, entry.Name())
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		}
+	}
+	wg.Wait()
+}
+
+func Test_SnapshotWhileRenaming(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a", 0o755))
+	require.NoError(t, memfs.MkdirAll("z", 0o755))
+	const files = 200
+	for i := 0; i < files; i++ {
+		require.NoError(t, memfs.WriteFile(fmt.Sprintf("a/file_%d.txt", i), []byte("hello"), 0o644))
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		from, to := "a", "z"
+		for {
+			for i := 0; i < files; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				name := fmt.Sprintf("file_%d.txt", i)
+				assert.NoError(t, memfs.Rename(from+"/"+name, to+"/"+name))
+			}
+			from, to = to, from
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		snapshot := memfs.Snapshot()
+		a, err := fs.ReadDir(snapshot, "a")
+		require.NoError(t, err)
+		z, err := fs.ReadDir(snapshot, "z")
+		require.NoError(t, err)
+		assert.Equal(t, files, len(a)+len(z))
+	}
+	close(done)
+	wg.Wait()
+}
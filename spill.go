@@ -0,0 +1,111 @@
+package memoryfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// spillFile is file content which has been moved out of memory into a temporary file on disk, see WithSpill.
+// It is reference counted, as snapshots share content with the filesystem they were taken from.
+type spillFile struct {
+	tree *tree
+	path string
+	size int64
+	refs int32
+}
+
+// spillRegistry tracks the temporary files in use by a tree, so that they can be removed by Close
+type spillRegistry struct {
+	sync.Mutex
+	files map[*spillFile]struct{}
+}
+
+// spill writes data to a new temporary file, if the tree is configured to spill content of this size
+func (t *tree) spill(data []byte) (*spillFile, error) {
+	if t.opts.spillThreshold <= 0 || int64(len(data)) <= t.opts.spillThreshold {
+		return nil, nil
+	}
+	f, err := ioutil.TempFile(t.opts.spillDir, "memoryfs-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	s := &spillFile{
+		tree: t,
+		path: f.Name(),
+		size: int64(len(data)),
+		refs: 1,
+	}
+	t.spills.Lock()
+	if t.spills.files == nil {
+		t.spills.files = map[*spillFile]struct{}{}
+	}
+	t.spills.files[s] = struct{}{}
+	t.spills.Unlock()
+	return s, nil
+}
+
+func (s *spillFile) retain() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+// release drops a reference to the content, removing the temporary file once it is no longer used
+func (s *spillFile) release() {
+	if atomic.AddInt32(&s.refs, -1) > 0 {
+		return
+	}
+	s.tree.spills.Lock()
+	delete(s.tree.spills.files, s)
+	s.tree.spills.Unlock()
+	_ = os.Remove(s.path)
+}
+
+// open returns a reader over the content, which must be closed after use
+func (s *spillFile) open() (io.ReadCloser, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return &spillReader{
+		SectionReader: io.NewSectionReader(f, 0, s.size),
+		file:          f,
+	}, nil
+}
+
+type spillReader struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (r *spillReader) Close() error {
+	return r.file.Close()
+}
+
+// Close releases resources held by the filesystem, removing any temporary files created by WithSpill. The content
+// of spilled files, including those in snapshots, can no longer be read once the filesystem is closed.
+func (m *FS) Close() error {
+	t := m.dir.tree
+	t.spills.Lock()
+	files := t.spills.files
+	t.spills.files = nil
+	t.spills.Unlock()
+
+	var firstErr error
+	for s := range files {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,114 @@
+package memoryfs
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spilledFiles(t *testing.T, dir string) int {
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	return len(entries)
+}
+
+func Test_SpillLargeFile(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(8, tmp))
+	defer func() { _ = memfs.Close() }()
+
+	require.NoError(t, memfs.WriteFile("small.txt", []byte("tiny"), 0o644))
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.WriteFile("large.txt", []byte("this is larger than eight bytes"), 0o644))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+
+	info, err := memfs.Stat("large.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(31), info.Size())
+
+	data, err := memfs.ReadFile("large.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "this is larger than eight bytes", string(data))
+
+	data, err = memfs.ReadAt("large.txt", 8, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "larger", string(data))
+
+	data, err = memfs.ReadFile("small.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "tiny", string(data))
+
+	size, err := memfs.DirSize(".")
+	require.NoError(t, err)
+	assert.Equal(t, int64(35), size)
+
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_SpillRemovedWithFile(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(4, tmp))
+	defer func() { _ = memfs.Close() }()
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("spilled content"), 0o644))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("more spilled content"), 0o644))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("mem"), 0o644))
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("spilled again"), 0o644))
+	require.NoError(t, memfs.Remove("file.txt"))
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+}
+
+func Test_SpillSharedWithSnapshot(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(4, tmp))
+	defer func() { _ = memfs.Close() }()
+
+	require.NoError(t, memfs.WriteFile("file.txt", []byte("spilled content"), 0o644))
+	snapshot := memfs.Snapshot()
+	require.NoError(t, memfs.Remove("file.txt"))
+	assert.Equal(t, 1, spilledFiles(t, tmp))
+
+	f, err := snapshot.Open("file.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "spilled content", string(data))
+}
+
+func Test_SpillClose(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(4, tmp))
+
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/one.txt", []byte("spilled content"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/b/two.txt", []byte("spilled content"), 0o644))
+	assert.Equal(t, 2, spilledFiles(t, tmp))
+
+	require.NoError(t, memfs.Close())
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+}
+
+func Test_SpillSkipsSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	memfs := New(WithSpill(4, tmp))
+	defer func() { _ = memfs.Close() }()
+
+	require.NoError(t, memfs.WriteFile("target.txt", []byte("x"), 0o644))
+	require.NoError(t, memfs.Symlink("target.txt", "link"))
+	assert.Equal(t, 0, spilledFiles(t, tmp))
+
+	target, err := memfs.ReadLink("link")
+	require.NoError(t, err)
+	assert.Equal(t, "target.txt", target)
+}
@@ -0,0 +1,42 @@
+package memoryfs
+
+import "math"
+
+// UnlimitedCapacity is the total capacity reported by StatFS for filesystems created without WithCapacity
+const UnlimitedCapacity = math.MaxInt64
+
+// FSStats describes the space used by a filesystem, as returned by StatFS
+type FSStats struct {
+	Total int64 // capacity in bytes, set with WithCapacity or UnlimitedCapacity
+	Used  int64 // sum of the sizes of all files, as reported by DirSize for the root
+	Free  int64 // Total less Used, or zero if Used exceeds Total
+}
+
+// WithCapacity sets the capacity in bytes reported as the total by StatFS, so that code checking for free space
+// before writing behaves as it would on a filesystem of that size. The capacity is only reported: writes beyond it
+// are not rejected. A capacity of zero or less means UnlimitedCapacity, which is the default.
+func WithCapacity(bytes int64) Option {
+	return func(o *options) {
+		o.capacity = bytes
+	}
+}
+
+// StatFS reports the capacity of the filesystem and how much of it is used, like statfs(2). The figures cover the
+// whole filesystem, even when called on a view returned by Sub.
+func (m *FS) StatFS() FSStats {
+	root := m.dir
+	for root.getParent() != nil {
+		root = root.getParent()
+	}
+	stats := FSStats{
+		Total: UnlimitedCapacity,
+		Used:  root.size(),
+	}
+	if capacity := m.dir.tree.opts.capacity; capacity > 0 {
+		stats.Total = capacity
+	}
+	if stats.Used < stats.Total {
+		stats.Free = stats.Total - stats.Used
+	}
+	return stats
+}
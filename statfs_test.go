@@ -0,0 +1,41 @@
+package memoryfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StatFS(t *testing.T) {
+	memfs := New(WithCapacity(100))
+	assert.Equal(t, FSStats{Total: 100, Used: 0, Free: 100}, memfs.StatFS())
+
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile(strings.ReplaceAll("dir/a.txt", "/", separator), []byte("0123456789"), 0o644))
+	assert.Equal(t, FSStats{Total: 100, Used: 10, Free: 90}, memfs.StatFS())
+
+	require.NoError(t, memfs.WriteFile("b.txt", []byte(strings.Repeat("b", 30)), 0o644))
+	assert.Equal(t, FSStats{Total: 100, Used: 40, Free: 60}, memfs.StatFS())
+
+	sub, err := memfs.Sub("dir")
+	require.NoError(t, err)
+	assert.Equal(t, FSStats{Total: 100, Used: 40, Free: 60}, sub.(*FS).StatFS())
+
+	require.NoError(t, memfs.WriteFile("c.txt", []byte(strings.Repeat("c", 80)), 0o644))
+	assert.Equal(t, FSStats{Total: 100, Used: 120, Free: 0}, memfs.StatFS())
+
+	require.NoError(t, memfs.Remove("c.txt"))
+	assert.Equal(t, FSStats{Total: 100, Used: 40, Free: 60}, memfs.StatFS())
+}
+
+func Test_StatFSUnlimited(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("a.txt", []byte("hello"), 0o644))
+
+	stats := memfs.StatFS()
+	assert.Equal(t, int64(UnlimitedCapacity), stats.Total)
+	assert.Equal(t, int64(5), stats.Used)
+	assert.Equal(t, int64(UnlimitedCapacity-5), stats.Free)
+}
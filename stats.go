@@ -0,0 +1,230 @@
+package memoryfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExtStat summarises the files sharing a file extension
+type ExtStat struct {
+	Count int
+	Bytes int64
+}
+
+// ExtStats walks the named directory and returns the number and total size of its regular files, grouped by file
+// extension including the leading dot (e.g. ".so"). Files without an extension are grouped under "".
+// As with Stat, sizes are not reliable for lazy files.
+func (m *FS) ExtStats(root string) (map[string]ExtStat, error) {
+	d, _, err := m.walkRoot("extstats", root)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]ExtStat{}
+	_ = d.walk("", func(_ string, _ *dir, f *file) error {
+		if f == nil {
+			return nil
+		}
+		info := f.stat()
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		ext := filepath.Ext(info.Name())
+		stat := stats[ext]
+		stat.Count++
+		stat.Bytes += info.Size()
+		stats[ext] = stat
+		return nil
+	})
+	return stats, nil
+}
+
+// DuplicateGroups walks the named directory and returns groups of paths to regular files with identical content.
+// Only groups with more than one member are returned. Paths are sorted within each group, and groups are sorted by
+// their first path. Each file is read once, as it is hashed. Files which the access checker denies opening are left
+// out, as their content cannot be read.
+func (m *FS) DuplicateGroups(root string) ([][]string, error) {
+	d, rootPath, err := m.walkRoot("duplicates", root)
+	if err != nil {
+		return nil, err
+	}
+	byHash := map[[sha256.Size]byte][]string{}
+	err = d.walk("", func(path string, _ *dir, f *file) error {
+		if f == nil || !f.stat().Mode().IsRegular() {
+			return nil
+		}
+		path = joinPath(rootPath, path, m.dir.sep())
+		if m.checkAccess("open", path) != nil {
+			return nil
+		}
+		access, err := f.open()
+		if err != nil {
+			return &fs.PathError{Op: "duplicates", Path: path, Err: err}
+		}
+		defer func() { _ = access.Close() }()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, access); err != nil {
+			return &fs.PathError{Op: "duplicates", Path: path, Err: err}
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], hash.Sum(nil))
+		byHash[sum] = append(byHash[sum], path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]string
+	for _, paths := range byHash {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		groups = append(groups, paths)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	return groups, nil
+}
+
+// SortedByMTime walks the named directory and returns the paths of all files and symbolic links beneath it, sorted
+// by modification time, oldest first if ascending is true and newest first otherwise. Files with the same
+// modification time are sorted by path.
+func (m *FS) SortedByMTime(root string, ascending bool) ([]string, error) {
+	d, rootPath, err := m.walkRoot("sortedbymtime", root)
+	if err != nil {
+		return nil, err
+	}
+	type entry struct {
+		path     string
+		modified time.Time
+	}
+	var entries []entry
+	_ = d.walk("", func(path string, _ *dir, f *file) error {
+		if f != nil {
+			entries = append(entries, entry{path: joinPath(rootPath, path, m.dir.sep()), modified: f.stat().ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.modified.Equal(b.modified) {
+			return a.modified.Before(b.modified) == ascending
+		}
+		return a.path < b.path
+	})
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.path)
+	}
+	return paths, nil
+}
+
+// FileSize is the path and size of a file, as returned by LargestFiles
+type FileSize struct {
+	Path string
+	Size int64
+}
+
+// LargestFiles walks the named directory and returns the n largest regular files beneath it, largest first. Files
+// of the same size are sorted by path. Fewer than n files are returned if there are not that many, and a negative
+// n returns an error wrapping fs.ErrInvalid. As with Stat, sizes are not reliable for lazy files.
+func (m *FS) LargestFiles(root string, n int) ([]FileSize, error) {
+	if n < 0 {
+		return nil, &fs.PathError{Op: "largestfiles", Path: root, Err: fs.ErrInvalid}
+	}
+	d, rootPath, err := m.walkRoot("largestfiles", root)
+	if err != nil {
+		return nil, err
+	}
+	var files []FileSize
+	_ = d.walk("", func(path string, _ *dir, f *file) error {
+		if f == nil {
+			return nil
+		}
+		if info := f.stat(); info.Mode().IsRegular() {
+			files = append(files, FileSize{Path: joinPath(rootPath, path, m.dir.sep()), Size: info.Size()})
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Size != files[j].Size {
+			return files[i].Size > files[j].Size
+		}
+		return files[i].Path < files[j].Path
+	})
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files, nil
+}
+
+// ModifiedSince walks the named directory and returns the sorted paths of all files and symbolic links beneath it
+// which were modified strictly after since.
+func (m *FS) ModifiedSince(root string, since time.Time) ([]string, error) {
+	d, rootPath, err := m.walkRoot("modifiedsince", root)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	_ = d.walk("", func(path string, _ *dir, f *file) error {
+		if f != nil && f.stat().ModTime().After(since) {
+			paths = append(paths, joinPath(rootPath, path, m.dir.sep()))
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// CommonPrefix returns the path of the deepest directory which contains every file in the filesystem, or "" if
+// there are no files or they diverge at the root. Empty directories are not considered. The result can be passed
+// to StripPrefix to remove a redundant wrapper directory.
+func (m *FS) CommonPrefix() string {
+	sep := m.dir.sep()
+	var prefix []string
+	var found bool
+	_ = m.dir.walk("", func(path string, _ *dir, f *file) error {
+		if f == nil {
+			return nil
+		}
+		parent, _ := splitPath(path, sep)
+		var parts []string
+		if parent != "" {
+			parts = strings.Split(parent, sep)
+		}
+		if !found {
+			prefix, found = parts, true
+			return nil
+		}
+		n := 0
+		for n < len(prefix) && n < len(parts) && prefix[n] == parts[n] {
+			n++
+		}
+		prefix = prefix[:n]
+		return nil
+	})
+	return strings.Join(prefix, sep)
+}
+
+// walkRoot resolves the named directory for a walk, returning it along with its cleansed path
+func (m *FS) walkRoot(op string, name string) (*dir, string, error) {
+	path, err := m.realpath(op, name, true)
+	if err != nil {
+		return nil, "", err
+	}
+	d, err := m.dir.getDir(path)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	if err := m.checkAccess("readdir", path); err != nil {
+		return nil, "", err
+	}
+	return d, path, nil
+}
@@ -0,0 +1,202 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExtStats(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("usr/lib/nested", 0o755))
+	require.NoError(t, memfs.WriteFile("usr/lib/libc.so", []byte("12345"), 0o644))
+	require.NoError(t, memfs.WriteFile("usr/lib/nested/libm.so", []byte("123"), 0o644))
+	require.NoError(t, memfs.WriteFile("usr/lib/README", []byte("readme"), 0o644))
+	require.NoError(t, memfs.WriteFile("usr/lib/archive.tar.gz", []byte("gz"), 0o644))
+	require.NoError(t, memfs.WriteFile("outside.so", []byte("ignored"), 0o644))
+	require.NoError(t, memfs.Symlink("libc.so", "usr/lib/libc.so.6"))
+
+	stats, err := memfs.ExtStats("usr")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ExtStat{
+		".so": {Count: 2, Bytes: 8},
+		"":    {Count: 1, Bytes: 6},
+		".gz": {Count: 1, Bytes: 2},
+	}, stats)
+
+	_, err = memfs.ExtStats("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_DuplicateGroups(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("root/a/b", 0o755))
+	require.NoError(t, memfs.WriteFile("root/one.txt", []byte("same"), 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/two.txt", []byte("same"), 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/b/three.txt", []byte("same"), 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/empty1", nil, 0o644))
+	require.NoError(t, memfs.WriteFile("root/a/b/empty2", nil, 0o644))
+	require.NoError(t, memfs.WriteFile("root/unique.txt", []byte("unique"), 0o644))
+	require.NoError(t, memfs.WriteFile("outside.txt", []byte("same"), 0o644))
+	require.NoError(t, memfs.Symlink("one.txt", "root/link"))
+
+	groups, err := memfs.DuplicateGroups("root")
+	require.NoError(t, err)
+
+	expected := [][]string{
+		{"root/a/b/empty2", "root/a/empty1"},
+		{"root/a/b/three.txt", "root/a/two.txt", "root/one.txt"},
+	}
+	for _, group := range expected {
+		for i := range group {
+			group[i] = strings.ReplaceAll(group[i], "/", separator)
+		}
+	}
+	assert.Equal(t, expected, groups)
+
+	groups, err = memfs.DuplicateGroups("root/a/b")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	_, err = memfs.DuplicateGroups("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_DuplicateGroupsAccessChecker(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.WriteFile("one.txt", []byte("same"), 0o644))
+	require.NoError(t, memfs.WriteFile("two.txt", []byte("same"), 0o600))
+	memfs.SetAccessChecker(func(op string, info fs.FileInfo) error {
+		if op == "open" && info.Mode().Perm()&0o004 == 0 {
+			return fs.ErrPermission
+		}
+		return nil
+	})
+
+	groups, err := memfs.DuplicateGroups(".")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	memfs.SetAccessChecker(nil)
+	groups, err = memfs.DuplicateGroups(".")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"one.txt", "two.txt"}}, groups)
+}
+
+func Test_SortedByMTime(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("logs/old", 0o755))
+	require.NoError(t, memfs.WriteFileAt("logs/c.log", nil, 0o644, base.Add(2*time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("logs/old/a.log", nil, 0o644, base))
+	require.NoError(t, memfs.WriteFileAt("logs/b.log", nil, 0o644, base.Add(time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("logs/a.log", nil, 0o644, base.Add(time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("other.log", nil, 0o644, base))
+
+	expected := []string{"logs/old/a.log", "logs/a.log", "logs/b.log", "logs/c.log"}
+	for i := range expected {
+		expected[i] = strings.ReplaceAll(expected[i], "/", separator)
+	}
+
+	paths, err := memfs.SortedByMTime("logs", true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, paths)
+
+	paths, err = memfs.SortedByMTime("logs", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{expected[3], expected[1], expected[2], expected[0]}, paths)
+
+	_, err = memfs.SortedByMTime("missing", true)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_ModifiedSince(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("logs/new", 0o755))
+	require.NoError(t, memfs.WriteFileAt("logs/old.log", nil, 0o644, base.Add(-time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("logs/same.log", nil, 0o644, base))
+	require.NoError(t, memfs.WriteFileAt("logs/new/b.log", nil, 0o644, base.Add(time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("logs/a.log", nil, 0o644, base.Add(2*time.Hour)))
+	require.NoError(t, memfs.WriteFileAt("other.log", nil, 0o644, base.Add(time.Hour)))
+
+	paths, err := memfs.ModifiedSince("logs", base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		strings.ReplaceAll("logs/a.log", "/", separator),
+		strings.ReplaceAll("logs/new/b.log", "/", separator),
+	}, paths)
+
+	paths, err = memfs.ModifiedSince("logs", base.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = memfs.ModifiedSince("missing", base)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_LargestFiles(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("data/nested", 0o755))
+	require.NoError(t, memfs.WriteFile("data/small.bin", []byte("1"), 0o644))
+	require.NoError(t, memfs.WriteFile("data/b.bin", []byte("12345"), 0o644))
+	require.NoError(t, memfs.WriteFile("data/nested/large.bin", []byte("1234567890"), 0o644))
+	require.NoError(t, memfs.WriteFile("data/a.bin", []byte("12345"), 0o644))
+	require.NoError(t, memfs.WriteFile("outside.bin", []byte(strings.Repeat("x", 100)), 0o644))
+	require.NoError(t, memfs.Symlink("nested/large.bin", "data/link"))
+
+	files, err := memfs.LargestFiles("data", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []FileSize{
+		{Path: strings.ReplaceAll("data/nested/large.bin", "/", separator), Size: 10},
+		{Path: strings.ReplaceAll("data/a.bin", "/", separator), Size: 5},
+		{Path: strings.ReplaceAll("data/b.bin", "/", separator), Size: 5},
+	}, files)
+
+	files, err = memfs.LargestFiles("data", 10)
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	files, err = memfs.LargestFiles("data", 0)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	_, err = memfs.LargestFiles("data", -1)
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	_, err = memfs.LargestFiles("missing", 3)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func Test_CommonPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string
+		dirs   []string
+		prefix string
+	}{
+		{name: "empty"},
+		{name: "single file", files: []string{"a/b/c.txt"}, prefix: "a/b"},
+		{name: "single file at root", files: []string{"c.txt"}, prefix: ""},
+		{name: "nested", files: []string{"wrapper/x/one.txt", "wrapper/x/y/two.txt", "wrapper/x/three.txt"}, prefix: "wrapper/x"},
+		{name: "divergent", files: []string{"a/one.txt", "b/two.txt"}, prefix: ""},
+		{name: "partially shared name", files: []string{"ab/one.txt", "abc/two.txt"}, prefix: ""},
+		{name: "empty directories ignored", files: []string{"wrapper/src/main.go"}, dirs: []string{"other/empty"}, prefix: "wrapper/src"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			memfs := New()
+			for _, dir := range test.dirs {
+				require.NoError(t, memfs.MkdirAll(dir, 0o755))
+			}
+			for _, file := range test.files {
+				require.NoError(t, memfs.WriteFileDeep(file, nil, 0o644))
+			}
+			assert.Equal(t, strings.ReplaceAll(test.prefix, "/", separator), memfs.CommonPrefix())
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// maxLinkHops is the maximum number of symbolic links followed while resolving a single path
+const maxLinkHops = 40
+
+// Symlink creates name as a symbolic link to target. Relative targets are resolved against the directory
+// containing the link, targets beginning with "/" are resolved from the root of the filesystem.
+// If name already exists, an error wrapping fs.ErrExist is returned.
+func (m *FS) Symlink(target, name string) error {
+	path, err := m.realpath("symlink", name, false)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkNameLen("symlink", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("symlink", path); err != nil {
+		return err
+	}
+	if _, err := m.dir.getFile(path); err == nil {
+		return &fs.PathError{Op: "symlink", Path: path, Err: fs.ErrExist}
+	}
+	if _, err := m.dir.getDir(path); err == nil {
+		return &fs.PathError{Op: "symlink", Path: path, Err: fs.ErrExist}
+	}
+	if err := m.checkAccess("write", path); err != nil {
+		return err
+	}
+	if err := m.dir.WriteFile(path, []byte(target), fs.ModeSymlink|0o777); err != nil {
+		return err
+	}
+	m.recordEvent(Event{Op: "symlink", Path: path, Target: target})
+	return nil
+}
+
+// OpenNoFollow opens the named file for reading as Open does, except that if the final component of name is a
+// symbolic link, the link itself is opened: reading the returned file yields the link target and Stat describes
+// the link. Symbolic links in earlier components are followed.
+func (m *FS) OpenNoFollow(name string) (fs.File, error) {
+	path, err := m.realpath("open", name, false)
+	if err != nil {
+		m.recordAccess("open", m.Clean(name))
+		return nil, err
+	}
+	m.recordAccess("open", path)
+	if err := m.checkAccess("open", path); err != nil {
+		return nil, err
+	}
+	return m.dir.Open(path)
+}
+
+// ReadLink returns the destination of the named symbolic link.
+func (m *FS) ReadLink(name string) (string, error) {
+	path, err := m.realpath("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	f, err := m.dir.getFile(path)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: path, Err: err}
+	}
+	if !f.isSymlink() {
+		return "", &fs.PathError{Op: "readlink", Path: path, Err: fs.ErrInvalid}
+	}
+	return f.linkTarget(), nil
+}
+
+// Symlinks walks the named directory and returns a map of the path of each symbolic link within it to its target,
+// as returned by ReadLink. Links are not followed. The map is empty if there are no links.
+func (m *FS) Symlinks(root string) (map[string]string, error) {
+	d, rootPath, err := m.walkRoot("symlinks", root)
+	if err != nil {
+		return nil, err
+	}
+	links := map[string]string{}
+	_ = d.walk("", func(path string, _ *dir, f *file) error {
+		if f != nil && f.isSymlink() {
+			links[joinPath(rootPath, path, m.dir.sep())] = f.linkTarget()
+		}
+		return nil
+	})
+	return links, nil
+}
+
+// Lstat returns a FileInfo describing the named file. If the file is a symbolic link, the returned FileInfo
+// describes the link itself rather than its target.
+func (m *FS) Lstat(name string) (fs.FileInfo, error) {
+	path, err := m.realpath("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return m.stat("lstat", path)
+}
+
+// realpath cleanses name and resolves any symbolic links within it. The final component is only resolved if
+// followFinal is true.
+func (m *FS) realpath(op string, name string, followFinal bool) (string, error) {
+	name = m.Clean(name)
+	resolved, err := m.resolve(name, followFinal)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return resolved, nil
+}
+
+// resolve expands symbolic links in a cleansed path, returning a path containing no links.
+// Components which do not exist are left as they are.
+func (m *FS) resolve(path string, followFinal bool) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	var resolved []string
+	remaining := splitLinkPath(path, m.dir.sep())
+	// cursor is the directory at the resolved path, or nil if it does not exist, so that each component is looked
+	// up in its parent directly rather than from the root
+	cursor := m.dir
+	locate := func() {
+		cursor, _ = m.dir.getDir(strings.Join(resolved, m.dir.sep()))
+	}
+	var hops int
+	for len(remaining) > 0 {
+		part := remaining[0]
+		remaining = remaining[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			locate()
+			continue
+		}
+		var f *file
+		var next *dir
+		if cursor != nil {
+			cursor.populate()
+			cursor.RLock()
+			f, next = cursor.files[part], cursor.dirs[part]
+			cursor.RUnlock()
+		}
+		if f == nil || !f.isSymlink() || (len(remaining) == 0 && !followFinal) {
+			resolved = append(resolved, part)
+			cursor = next
+			continue
+		}
+		hops++
+		if hops > maxLinkHops {
+			return "", ErrSymlinkLoop
+		}
+		target := f.linkTarget()
+		if strings.HasPrefix(target, "/") || strings.HasPrefix(target, m.dir.sep()) {
+			resolved = nil
+		}
+		remaining = append(splitLinkPath(target, m.dir.sep()), remaining...)
+		locate()
+	}
+	return strings.Join(resolved, m.dir.sep()), nil
+}
+
+func splitLinkPath(path string, sep string) []string {
+	return strings.Split(fromSlash(path, sep), sep)
+}
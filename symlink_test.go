@@ -0,0 +1,194 @@
+package memoryfs
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Symlink(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("data/config", 0o700))
+	require.NoError(t, memfs.WriteFile("data/config/app.yaml", []byte("key: value"), 0o644))
+	require.NoError(t, memfs.Symlink("data/config/app.yaml", "app.yaml"))
+	require.NoError(t, memfs.Symlink("/data/config", "data/cfg"))
+
+	t.Run("ReadLink", func(t *testing.T) {
+		target, err := memfs.ReadLink("app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "data/config/app.yaml", target)
+
+		_, err = memfs.ReadLink("data/config/app.yaml")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("Open follows link", func(t *testing.T) {
+		data, err := memfs.ReadFile("app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "key: value", string(data))
+
+		data, err = memfs.ReadFile("data/cfg/app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "key: value", string(data))
+	})
+
+	t.Run("Stat follows link", func(t *testing.T) {
+		info, err := memfs.Stat("app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "app.yaml", info.Name())
+		assert.Equal(t, fs.FileMode(0o644), info.Mode())
+
+		info, err = memfs.Stat("data/cfg")
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+	})
+
+	t.Run("Lstat does not follow link", func(t *testing.T) {
+		info, err := memfs.Lstat("app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+		assert.False(t, info.IsDir())
+	})
+
+	t.Run("ReadDir through link", func(t *testing.T) {
+		entries, err := memfs.ReadDir("data/cfg")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "app.yaml", entries[0].Name())
+	})
+
+	t.Run("Existing name", func(t *testing.T) {
+		assert.ErrorIs(t, memfs.Symlink("anything", "app.yaml"), fs.ErrExist)
+		assert.ErrorIs(t, memfs.Symlink("anything", "data"), fs.ErrExist)
+	})
+
+	t.Run("Dangling link", func(t *testing.T) {
+		require.NoError(t, memfs.Symlink("missing.txt", "dangling"))
+		_, err := memfs.Stat("dangling")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = memfs.Lstat("dangling")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Loop", func(t *testing.T) {
+		require.NoError(t, memfs.Symlink("loop-b", "loop-a"))
+		require.NoError(t, memfs.Symlink("loop-a", "loop-b"))
+		_, err := memfs.Stat("loop-a")
+		assert.ErrorIs(t, err, ErrSymlinkLoop)
+	})
+
+	t.Run("Remove removes link", func(t *testing.T) {
+		require.NoError(t, memfs.Symlink("data/config/app.yaml", "removable"))
+		require.NoError(t, memfs.Remove("removable"))
+		_, err := memfs.Lstat("removable")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = memfs.Stat("data/config/app.yaml")
+		assert.NoError(t, err)
+	})
+
+	t.Run("OpenNoFollow opens link", func(t *testing.T) {
+		f, err := memfs.OpenNoFollow("app.yaml")
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		info, err := f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "data/config/app.yaml", string(data))
+	})
+
+	t.Run("OpenNoFollow follows parent links", func(t *testing.T) {
+		f, err := memfs.OpenNoFollow("data/cfg/app.yaml")
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "key: value", string(data))
+	})
+
+	t.Run("Write through link", func(t *testing.T) {
+		require.NoError(t, memfs.WriteFile("app.yaml", []byte("key: other"), 0o644))
+		data, err := memfs.ReadFile("data/config/app.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "key: other", string(data))
+	})
+}
+
+func Test_SymlinkRelativeTargets(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.MkdirAll("a/c", 0o700))
+	require.NoError(t, memfs.MkdirAll("x/y", 0o700))
+	require.NoError(t, memfs.MkdirAll("c", 0o700))
+	require.NoError(t, memfs.WriteFile("a/c/file", []byte("a/c"), 0o644))
+	require.NoError(t, memfs.WriteFile("c/file", []byte("c"), 0o644))
+	require.NoError(t, memfs.Symlink("../c/file", "a/b/link"))
+	require.NoError(t, memfs.Symlink("/c/file", "a/b/absolute"))
+	require.NoError(t, memfs.Symlink("../../a/b", "x/y/b"))
+
+	t.Run("Relative to link directory", func(t *testing.T) {
+		data, err := memfs.ReadFile("a/b/link")
+		require.NoError(t, err)
+		assert.Equal(t, "a/c", string(data))
+	})
+
+	t.Run("Absolute from root", func(t *testing.T) {
+		data, err := memfs.ReadFile("a/b/absolute")
+		require.NoError(t, err)
+		assert.Equal(t, "c", string(data))
+	})
+
+	t.Run("Relative to link target after following parent link", func(t *testing.T) {
+		data, err := memfs.ReadFile("x/y/b/link")
+		require.NoError(t, err)
+		assert.Equal(t, "a/c", string(data))
+
+		info, err := memfs.Stat("x/y/b/link")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), info.Size())
+	})
+
+	t.Run("Parent of root is root", func(t *testing.T) {
+		require.NoError(t, memfs.Symlink("../../../../c/file", "a/b/escape"))
+		data, err := memfs.ReadFile("a/b/escape")
+		require.NoError(t, err)
+		assert.Equal(t, "c", string(data))
+	})
+}
+
+func Test_Symlinks(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/file.txt", nil, 0o644))
+	require.NoError(t, memfs.Symlink("file.txt", "a/link"))
+	require.NoError(t, memfs.Symlink("../missing", "a/b/dangling"))
+	require.NoError(t, memfs.Symlink("/a", "root"))
+
+	links, err := memfs.Symlinks(".")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		strings.ReplaceAll("a/link", "/", separator):       "file.txt",
+		strings.ReplaceAll("a/b/dangling", "/", separator): "../missing",
+		"root": "/a",
+	}, links)
+
+	links, err = memfs.Symlinks("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		strings.ReplaceAll("a/b/dangling", "/", separator): "../missing",
+	}, links)
+
+	require.NoError(t, memfs.MkdirAll("empty", 0o700))
+	links, err = memfs.Symlinks("empty")
+	require.NoError(t, err)
+	assert.NotNil(t, links)
+	assert.Empty(t, links)
+
+	_, err = memfs.Symlinks("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
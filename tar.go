@@ -0,0 +1,162 @@
+package memoryfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"time"
+)
+
+// ReadTar reads a tar archive from r and writes its directories, regular files and symbolic links into the
+// filesystem, with their modes and modification times. Entry paths are validated so that none can escape the root
+// (see WithSecureImport), absolute paths are re-rooted. Files and links replace any file or link already at their
+// path, so that archives can be layered. Other entry types are ignored.
+func (m *FS) ReadTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		path, ok, err := m.importPath("readtar", header.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		perm := fs.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := m.importDir(path, perm, header.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("failed to read tar entry '%s': %w", header.Name, err)
+			}
+			if err := m.importFile(path, data, perm, header.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := m.importLink(path, header.Linkname, perm, header.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ReadTarGz reads a gzip-compressed tar archive from r, as ReadTar does.
+func (m *FS) ReadTarGz(r io.Reader) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	defer func() { _ = gr.Close() }()
+	return m.ReadTar(gr)
+}
+
+// FromTarGz creates a new filesystem holding the contents of the gzip-compressed tar archive read from r, such as
+// a container image layer, as ReadTarGz does. Entries whose paths would escape the root cause an error wrapping
+// ErrUnsafePath. If the archive cannot be read, the error is returned and no filesystem is. Later layers can be
+// applied with ReadTarGz, their files and symbolic links replacing those of earlier layers.
+func FromTarGz(r io.Reader) (*FS, error) {
+	m := New()
+	if err := m.ReadTarGz(r); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteTar writes the whole filesystem to w as a tar archive. Directories, regular files and symbolic links are
+// written in lexical order, with their modes and modification times. Lazy files are read in order to be written.
+func (m *FS) WriteTar(w io.Writer) error {
+	return m.writeTar(w, false)
+}
+
+// WriteTarReproducible writes the whole filesystem to w as WriteTar does, but with normalised metadata so that
+// identical trees always produce byte-identical archives: every modification time is the Unix epoch, ownership is
+// omitted, and modes are 0755 for directories and executable files and 0644 otherwise.
+func (m *FS) WriteTarReproducible(w io.Writer) error {
+	return m.writeTar(w, true)
+}
+
+func (m *FS) writeTar(w io.Writer, reproducible bool) error {
+	tw := tar.NewWriter(w)
+	header := func(h *tar.Header, info fs.FileInfo) *tar.Header {
+		h.Mode = int64(info.Mode().Perm())
+		h.ModTime = info.ModTime()
+		if reproducible {
+			h.ModTime = time.Unix(0, 0)
+			h.Mode = 0o644
+			if info.IsDir() || info.Mode()&0o111 != 0 {
+				h.Mode = 0o755
+			}
+		}
+		return h
+	}
+	err := m.dir.walk("", func(path string, d *dir, f *file) error {
+		if path == "" {
+			return nil
+		}
+		name := toSlash(path, m.dir.sep())
+		if d != nil {
+			info, _ := d.Stat()
+			return tw.WriteHeader(header(&tar.Header{
+				Name:     name + "/",
+				Typeflag: tar.TypeDir,
+			}, info))
+		}
+		info := f.stat()
+		if f.isSymlink() {
+			return tw.WriteHeader(header(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.linkTarget(),
+			}, info))
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := f.readAll()
+		if err != nil {
+			return fmt.Errorf("failed to read file '%s': %w", path, err)
+		}
+		if err := tw.WriteHeader(header(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Size:     int64(len(data)),
+		}, info)); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write tar: %w", err)
+	}
+	return tw.Close()
+}
+
+// WriteTarGz writes the whole filesystem to w as a gzip-compressed tar archive, as WriteTar does. The compression
+// level can be set with WithGzipLevel.
+func (m *FS) WriteTarGz(w io.Writer) error {
+	gw, err := gzip.NewWriterLevel(w, m.dir.tree.opts.gzipLevel)
+	if err != nil {
+		return err
+	}
+	if err := m.WriteTar(gw); err != nil {
+		_ = gw.Close()
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,455 @@
+package memoryfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	mode     int64
+	linkname string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	buffer := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		mode := entry.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Mode:     mode,
+			Size:     int64(len(entry.content)),
+			Linkname: entry.linkname,
+			ModTime:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		}))
+		if entry.content != "" {
+			_, err := tw.Write([]byte(entry.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return buffer.Bytes()
+}
+
+func Test_ReadTar(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "etc/", typeflag: tar.TypeDir, mode: 0o750},
+		{name: "etc/hosts", typeflag: tar.TypeReg, content: "127.0.0.1 localhost"},
+		{name: "usr/bin/tool", typeflag: tar.TypeReg, content: "#!/bin/sh", mode: 0o755},
+	})
+
+	memfs := New()
+	require.NoError(t, memfs.ReadTar(bytes.NewReader(archive)))
+
+	data, err := memfs.ReadFile("etc/hosts")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost", string(data))
+
+	info, err := memfs.Stat("etc")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o750)|fs.ModeDir, info.Mode())
+
+	info, err = memfs.Stat("usr/bin/tool")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o755), info.Mode())
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(info.ModTime()))
+}
+
+func Test_ReadTarRejectsTraversal(t *testing.T) {
+	for _, name := range []string{
+		"../../etc/cron.d/evil",
+		"a/../../evil",
+		"..",
+	} {
+		t.Run(name, func(t *testing.T) {
+			archive := buildTar(t, []tarEntry{
+				{name: name, typeflag: tar.TypeReg, content: "evil"},
+			})
+
+			memfs := New()
+			err := memfs.ReadTar(bytes.NewReader(archive))
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrUnsafePath)
+
+			entries, err := memfs.ReadDir(".")
+			require.NoError(t, err)
+			assert.Len(t, entries, 0)
+		})
+	}
+}
+
+func Test_ReadTarSkipsTraversal(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "../../etc/cron.d/evil", typeflag: tar.TypeReg, content: "evil"},
+		{name: "good.txt", typeflag: tar.TypeReg, content: "good"},
+	})
+
+	memfs := New(WithSecureImport(true))
+	require.NoError(t, memfs.ReadTar(bytes.NewReader(archive)))
+
+	entries, err := memfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "good.txt", entries[0].Name())
+}
+
+func Test_ReadTarReRootsAbsolutePaths(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "/etc/passwd", typeflag: tar.TypeReg, content: "root:x:0:0"},
+		{name: "/../etc/shadow", typeflag: tar.TypeReg, content: "root:*"},
+	})
+
+	memfs := New()
+	require.NoError(t, memfs.ReadTar(bytes.NewReader(archive)))
+
+	data, err := memfs.ReadFile("etc/passwd")
+	require.NoError(t, err)
+	assert.Equal(t, "root:x:0:0", string(data))
+
+	data, err = memfs.ReadFile("etc/shadow")
+	require.NoError(t, err)
+	assert.Equal(t, "root:*", string(data))
+}
+
+func Test_WriteTar(t *testing.T) {
+	modified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("etc/ssl", 0o750))
+	require.NoError(t, memfs.WriteFileAt("etc/hosts", []byte("127.0.0.1 localhost"), 0o644, modified))
+	require.NoError(t, memfs.WriteFile("etc/ssl/cert.pem", []byte("cert"), 0o600))
+	require.NoError(t, memfs.Symlink("hosts", "etc/hosts.link"))
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, memfs.WriteTar(buffer))
+
+	var names []string
+	tr := tar.NewReader(buffer)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		switch header.Name {
+		case "etc/hosts":
+			assert.True(t, modified.Equal(header.ModTime))
+			assert.Equal(t, int64(0o644), header.Mode)
+		case "etc/hosts.link":
+			assert.Equal(t, byte(tar.TypeSymlink), header.Typeflag)
+			assert.Equal(t, "hosts", header.Linkname)
+		case "etc/ssl/":
+			assert.Equal(t, byte(tar.TypeDir), header.Typeflag)
+			assert.Equal(t, int64(0o750), header.Mode)
+		}
+	}
+	assert.Equal(t, []string{"etc/", "etc/hosts", "etc/hosts.link", "etc/ssl/", "etc/ssl/cert.pem"}, names)
+}
+
+func Test_TarGzRoundTrip(t *testing.T) {
+	modified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, level := range []int{gzip.DefaultCompression, gzip.NoCompression, gzip.BestCompression} {
+		original := New(WithGzipLevel(level))
+		require.NoError(t, original.MkdirAll("a/b", 0o750))
+		require.NoError(t, original.MkdirAll("empty", 0o700))
+		require.NoError(t, original.WriteFileAt("a/one.txt", []byte("one"), 0o644, modified))
+		require.NoError(t, original.WriteFileAt("a/b/two.txt", bytes.Repeat([]byte("two"), 1000), 0o600, modified))
+
+		buffer := bytes.NewBuffer(nil)
+		require.NoError(t, original.WriteTarGz(buffer))
+
+		restored := New()
+		require.NoError(t, restored.ReadTarGz(buffer))
+
+		for _, path := range []string{"a", "a/b", "empty", "a/one.txt", "a/b/two.txt"} {
+			expected, err := original.Stat(path)
+			require.NoError(t, err)
+			actual, err := restored.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Mode(), actual.Mode(), path)
+			assert.Equal(t, expected.Size(), actual.Size(), path)
+		}
+		data, err := restored.ReadFile("a/b/two.txt")
+		require.NoError(t, err)
+		assert.Equal(t, bytes.Repeat([]byte("two"), 1000), data)
+
+		info, err := restored.Stat("a/one.txt")
+		require.NoError(t, err)
+		assert.True(t, modified.Equal(info.ModTime()))
+	}
+}
+
+func Test_ReadTarSymlinks(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "usr/bin/tool", typeflag: tar.TypeReg, content: "#!/bin/sh", mode: 0o755},
+		{name: "bin", typeflag: tar.TypeSymlink, linkname: "usr/bin", mode: 0o777},
+		{name: "opt/tool", typeflag: tar.TypeSymlink, linkname: "/usr/bin/tool", mode: 0o777},
+	})
+
+	memfs := New()
+	require.NoError(t, memfs.ReadTar(bytes.NewReader(archive)))
+
+	target, err := memfs.ReadLink("bin")
+	require.NoError(t, err)
+	assert.Equal(t, "usr/bin", target)
+	info, err := memfs.Lstat("bin")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeSymlink|0o777, info.Mode())
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(info.ModTime()))
+
+	data, err := memfs.ReadFile("opt/tool")
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh", string(data))
+	data, err = memfs.ReadFile("bin/tool")
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh", string(data))
+}
+
+func Test_TarSymlinkRoundTrip(t *testing.T) {
+	original := New()
+	require.NoError(t, original.MkdirAll("a", 0o750))
+	require.NoError(t, original.WriteFile("a/one.txt", []byte("one"), 0o644))
+	require.NoError(t, original.Symlink("one.txt", "a/link"))
+	require.NoError(t, original.Symlink("/a", "root-link"))
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, original.WriteTar(buffer))
+
+	restored := New()
+	require.NoError(t, restored.ReadTar(buffer))
+	for _, path := range []string{"a/link", "root-link"} {
+		expected, err := original.ReadLink(path)
+		require.NoError(t, err)
+		actual, err := restored.ReadLink(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, path)
+
+		expectedInfo, err := original.Lstat(path)
+		require.NoError(t, err)
+		actualInfo, err := restored.Lstat(path)
+		require.NoError(t, err)
+		assert.Equal(t, expectedInfo.Mode(), actualInfo.Mode(), path)
+	}
+}
+
+func Test_WriteTarGzInvalidLevel(t *testing.T) {
+	memfs := New(WithGzipLevel(42))
+	assert.Error(t, memfs.WriteTarGz(bytes.NewBuffer(nil)))
+}
+
+func Test_ReadTarGzInvalid(t *testing.T) {
+	memfs := New()
+	assert.Error(t, memfs.ReadTarGz(bytes.NewReader([]byte("not gzip"))))
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buffer := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buffer)
+	_, err := gw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	return buffer.Bytes()
+}
+
+func Test_FromTarGz(t *testing.T) {
+	layer := gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "./", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "./etc/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "./etc/os-release", typeflag: tar.TypeReg, content: "ID=alpine\n"},
+		{name: "./usr/bin/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "./usr/bin/app", typeflag: tar.TypeReg, content: "#!/bin/sh\n", mode: 0o755},
+		{name: "./usr/lib/.wh.old.so", typeflag: tar.TypeReg},
+		{name: "./bin", typeflag: tar.TypeSymlink, linkname: "usr/bin"},
+	}))
+
+	memfs, err := FromTarGz(bytes.NewReader(layer))
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("etc/os-release")
+	require.NoError(t, err)
+	assert.Equal(t, "ID=alpine\n", string(data))
+	info, err := memfs.Stat("usr/bin/app")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o755), info.Mode())
+	_, err = memfs.Stat("usr/lib/.wh.old.so")
+	assert.NoError(t, err)
+	target, err := memfs.ReadLink("bin")
+	require.NoError(t, err)
+	assert.Equal(t, "usr/bin", target)
+	data, err = memfs.ReadFile("bin/app")
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(data))
+
+	// the filesystem is mutable
+	require.NoError(t, memfs.WriteFile("etc/hostname", []byte("scanner"), 0o644))
+}
+
+func Test_ReadTarGzLayers(t *testing.T) {
+	base := gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "./usr/lib/libc.so", typeflag: tar.TypeReg, content: "libc"},
+		{name: "./lib", typeflag: tar.TypeReg, content: "placeholder"},
+		{name: "./etc/config", typeflag: tar.TypeSymlink, linkname: "/usr/lib/libc.so"},
+	}))
+	upper := gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "./lib", typeflag: tar.TypeSymlink, linkname: "usr/lib"},
+		{name: "./etc/config", typeflag: tar.TypeReg, content: "key=value"},
+	}))
+
+	memfs, err := FromTarGz(bytes.NewReader(base))
+	require.NoError(t, err)
+	require.NoError(t, memfs.ReadTarGz(bytes.NewReader(upper)))
+
+	// a link in a later layer replaces a file in an earlier one
+	target, err := memfs.ReadLink("lib")
+	require.NoError(t, err)
+	assert.Equal(t, "usr/lib", target)
+	data, err := memfs.ReadFile("lib/libc.so")
+	require.NoError(t, err)
+	assert.Equal(t, "libc", string(data))
+
+	// and a file replaces a link rather than writing through it
+	info, err := memfs.Lstat("etc/config")
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	data, err = memfs.ReadFile("etc/config")
+	require.NoError(t, err)
+	assert.Equal(t, "key=value", string(data))
+	data, err = memfs.ReadFile("usr/lib/libc.so")
+	require.NoError(t, err)
+	assert.Equal(t, "libc", string(data))
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_FromTarGzInvalid(t *testing.T) {
+	memfs, err := FromTarGz(bytes.NewReader([]byte("not gzip")))
+	assert.Error(t, err)
+	assert.Nil(t, memfs)
+
+	truncated := gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "file.txt", typeflag: tar.TypeReg, content: "content"},
+	}))
+	memfs, err = FromTarGz(bytes.NewReader(truncated[:len(truncated)/2]))
+	assert.Error(t, err)
+	assert.Nil(t, memfs)
+
+	memfs, err = FromTarGz(bytes.NewReader(gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "../escape.txt", typeflag: tar.TypeReg, content: "content"},
+	}))))
+	assert.ErrorIs(t, err, ErrUnsafePath)
+	assert.Nil(t, memfs)
+}
+
+func Test_WriteTarReproducible(t *testing.T) {
+	build := func(modified time.Time) *FS {
+		memfs := New()
+		require.NoError(t, memfs.MkdirAll("b/bin", 0o700))
+		require.NoError(t, memfs.MkdirAll("a", 0o750))
+		require.NoError(t, memfs.WriteFileAt("b/bin/tool", []byte("#!/bin/sh"), 0o700, modified))
+		require.NoError(t, memfs.WriteFileAt("a/config", []byte("key: value"), 0o600, modified))
+		require.NoError(t, memfs.Symlink("../a/config", "b/config"))
+		return memfs
+	}
+
+	first := bytes.NewBuffer(nil)
+	require.NoError(t, build(time.Now()).WriteTarReproducible(first))
+	time.Sleep(10 * time.Millisecond)
+	second := bytes.NewBuffer(nil)
+	require.NoError(t, build(time.Now().Add(time.Hour)).WriteTarReproducible(second))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	modes := map[string]int64{}
+	tr := tar.NewReader(first)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), header.ModTime.Unix(), header.Name)
+		assert.Equal(t, 0, header.Uid)
+		assert.Equal(t, 0, header.Gid)
+		modes[header.Name] = header.Mode
+	}
+	assert.Equal(t, int64(0o755), modes["a/"])
+	assert.Equal(t, int64(0o755), modes["b/bin/tool"])
+	assert.Equal(t, int64(0o644), modes["a/config"])
+}
+
+func Test_ReadTarMaxEntries(t *testing.T) {
+	entries := []tarEntry{
+		{name: "root/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "root/a.txt", typeflag: tar.TypeReg, content: "a"},
+	}
+	for i := 0; i < 100; i++ {
+		entries = append(entries, tarEntry{name: fmt.Sprintf("root/d%d/", i), typeflag: tar.TypeDir, mode: 0o755})
+	}
+	archive := buildTar(t, entries)
+
+	memfs := New(WithMaxEntries(10))
+	err := memfs.ReadTar(bytes.NewReader(archive))
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+	require.NoError(t, memfs.Verify())
+
+	var count int
+	require.NoError(t, fs.WalkDir(memfs, ".", func(path string, _ fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if path != "." {
+			count++
+		}
+		return nil
+	}))
+	assert.Equal(t, 10, count)
+	data, err := memfs.ReadFile(strings.ReplaceAll("root/a.txt", "/", separator))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+}
+
+func Test_ReadTarConcurrentStat(t *testing.T) {
+	archive := buildTar(t, []tarEntry{
+		{name: "etc/", typeflag: tar.TypeDir, mode: 0o750},
+		{name: "etc/hosts", typeflag: tar.TypeReg, content: "127.0.0.1 localhost"},
+	})
+
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("etc", 0o750))
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			info, err := memfs.Stat("etc")
+			assert.NoError(t, err)
+			_ = info.ModTime()
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, memfs.ReadTar(bytes.NewReader(archive)))
+	}
+	close(stop)
+	<-done
+
+	info, err := memfs.Stat("etc")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(info.ModTime()))
+}
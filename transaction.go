@@ -0,0 +1,224 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"time"
+)
+
+// Tx stages the changes made within a call to Transaction
+type Tx struct {
+	fs     *FS
+	steps  []txStep
+	events []Event // recorded in the event log once the transaction commits, see WithEventLog
+}
+
+// txStep records how to undo a change made within a transaction, and how to finish it once the transaction
+// commits
+type txStep struct {
+	undo   func()
+	commit func()
+}
+
+// Transaction calls fn with a Tx through which to change the filesystem. If fn returns nil, the changes are kept.
+// If fn returns an error, every change made through the Tx is rolled back in reverse order and the error is
+// returned. Changes are applied as they are made, so they are visible to concurrent readers before fn returns, and
+// other writes made concurrently to the same paths may be lost when rolling back. Content written through the
+// opener of a lazy file is not rolled back. If the filesystem was created WithEventLog, the changes are recorded
+// once the transaction commits, and not at all if it is rolled back.
+func (m *FS) Transaction(fn func(tx *Tx) error) error {
+	tx := &Tx{fs: m}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	for _, step := range tx.steps {
+		if step.commit != nil {
+			step.commit()
+		}
+	}
+	for _, event := range tx.events {
+		m.recordEvent(event)
+	}
+	return nil
+}
+
+func (tx *Tx) rollback() {
+	for i := len(tx.steps) - 1; i >= 0; i-- {
+		tx.steps[i].undo()
+	}
+	tx.steps = nil
+}
+
+// discard removes the entry at the resolved path as part of rolling back
+func (tx *Tx) discard(path string) {
+	if entry, err := tx.fs.detach(path, true); err == nil {
+		entry.discard()
+	}
+}
+
+// WriteFile writes data to the named file as FS.WriteFile does.
+func (tx *Tx) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m := tx.fs
+	path, err := m.realpath("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNameLen("write", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("write", path); err != nil {
+		return err
+	}
+	var previous *file
+	if f, err := m.dir.getFile(path); err == nil {
+		previous = f.clone()
+	}
+	modified := time.Now()
+	if err := m.writeFileAt(path, data, perm, modified); err != nil {
+		if previous != nil {
+			previous.discard()
+		}
+		return err
+	}
+	tx.events = append(tx.events, Event{Op: "write", Path: path, Data: data, Mode: perm, ModTime: modified})
+	if previous == nil {
+		tx.steps = append(tx.steps, txStep{
+			undo: func() { tx.discard(path) },
+		})
+		return nil
+	}
+	tx.steps = append(tx.steps, txStep{
+		undo: func() {
+			tx.discard(path)
+			if err := m.attach(path, trashEntry{file: previous}); err != nil {
+				previous.discard()
+			}
+		},
+		commit: previous.discard,
+	})
+	return nil
+}
+
+// Mkdir creates the named directory with the given permissions. The parent directory must exist, and an error
+// wrapping fs.ErrExist is returned if something already exists at the path.
+func (tx *Tx) Mkdir(name string, perm fs.FileMode) error {
+	m := tx.fs
+	path, err := m.realpath("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parentPath, _ := splitPath(path, m.dir.sep())
+	if _, err := m.dir.getDir(parentPath); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+	if _, err := m.dir.getDir(path); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+	}
+	if _, err := m.dir.getFile(path); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+	}
+	if err := m.checkNameLen("mkdir", path); err != nil {
+		return err
+	}
+	if err := m.checkDepth("mkdir", path); err != nil {
+		return err
+	}
+	if err := m.dir.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	tx.events = append(tx.events, Event{Op: "mkdir", Path: path, Mode: perm})
+	tx.steps = append(tx.steps, txStep{
+		undo: func() { tx.discard(path) },
+	})
+	return nil
+}
+
+// Remove removes the named file or empty directory as FS.Remove does. The entry is kept until the transaction
+// commits, so that it can be restored if the transaction is rolled back.
+func (tx *Tx) Remove(name string) error {
+	m := tx.fs
+	path, err := m.realpath("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, err := m.detach(path, false)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: path, Err: err}
+	}
+	tx.events = append(tx.events, Event{Op: "remove", Path: path})
+	tx.steps = append(tx.steps, txStep{
+		undo: func() {
+			if err := m.attach(path, entry); err != nil {
+				entry.discard()
+			}
+		},
+		commit: func() {
+			if m.dir.tree.opts.trash {
+				m.addToTrash(path, entry)
+				return
+			}
+			entry.discard()
+		},
+	})
+	return nil
+}
+
+// Rename renames (moves) oldpath to newpath as FS.Rename does. Any file or empty directory replaced at newpath is
+// kept until the transaction commits, so that it can be restored if the transaction is rolled back.
+func (tx *Tx) Rename(oldpath, newpath string) error {
+	m := tx.fs
+	src, err := m.realpath("rename", oldpath, false)
+	if err != nil {
+		return err
+	}
+	dst, err := m.realpath("rename", newpath, false)
+	if err != nil {
+		return err
+	}
+
+	// detach whatever Rename would replace, so that it is not discarded
+	var replaced *trashEntry
+	if src != dst {
+		_, srcFileErr := m.dir.getFile(src)
+		_, dstFileErr := m.dir.getFile(dst)
+		_, srcDirErr := m.dir.getDir(src)
+		_, dstDirErr := m.dir.getDir(dst)
+		if (srcFileErr == nil && dstFileErr == nil) || (src != "" && dst != "" && srcDirErr == nil && dstDirErr == nil) {
+			if entry, err := m.detach(dst, false); err == nil {
+				replaced = &entry
+			}
+		}
+	}
+	restore := func() {
+		if replaced == nil {
+			return
+		}
+		if err := m.attach(dst, *replaced); err != nil {
+			replaced.discard()
+		}
+	}
+
+	if err := m.rename(src, dst); err != nil {
+		restore()
+		return err
+	}
+	tx.events = append(tx.events, Event{Op: "rename", Path: src, NewPath: dst})
+	tx.steps = append(tx.steps, txStep{
+		undo: func() {
+			_ = m.rename(dst, src)
+			restore()
+		},
+		commit: func() {
+			if replaced != nil {
+				replaced.discard()
+			}
+		},
+	})
+	return nil
+}
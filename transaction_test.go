@@ -0,0 +1,116 @@
+package memoryfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTransactionFS(t *testing.T, opts ...Option) *FS {
+	memfs := New(opts...)
+	require.NoError(t, memfs.MkdirAll("dir/empty", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	require.NoError(t, memfs.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	require.NoError(t, memfs.WriteFile("dir/c.txt", []byte("c"), 0o644))
+	return memfs
+}
+
+func readString(t *testing.T, memfs *FS, name string) string {
+	data, err := memfs.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func Test_TransactionCommit(t *testing.T) {
+	memfs := newTransactionFS(t)
+
+	err := memfs.Transaction(func(tx *Tx) error {
+		if err := tx.Mkdir("dir/new", 0o755); err != nil {
+			return err
+		}
+		if err := tx.WriteFile("dir/new/file.txt", []byte("new"), 0o600); err != nil {
+			return err
+		}
+		if err := tx.WriteFile("dir/a.txt", []byte("changed"), 0o644); err != nil {
+			return err
+		}
+		if err := tx.Remove("dir/b.txt"); err != nil {
+			return err
+		}
+		return tx.Rename("dir/c.txt", "dir/a.txt")
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "new", readString(t, memfs, "dir/new/file.txt"))
+	assert.Equal(t, "c", readString(t, memfs, "dir/a.txt"))
+	_, err = memfs.Stat("dir/b.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = memfs.Stat("dir/c.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_TransactionRollback(t *testing.T) {
+	memfs := newTransactionFS(t, WithMaxFiles(4))
+	failure := errors.New("failure")
+	err := memfs.Transaction(func(tx *Tx) error {
+		require.NoError(t, tx.Mkdir("dir/new", 0o755))
+		require.NoError(t, tx.WriteFile("dir/new/file.txt", []byte("new"), 0o600))
+		require.NoError(t, tx.WriteFile("dir/a.txt", []byte("changed"), 0o644))
+		require.NoError(t, tx.WriteFile("dir/a.txt", []byte("changed again"), 0o644))
+		require.NoError(t, tx.Remove("dir/b.txt"))
+		require.NoError(t, tx.Rename("dir/c.txt", "dir/a.txt"))
+		require.NoError(t, tx.Rename("dir/new", "dir/empty"))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	assert.Equal(t, "a", readString(t, memfs, "dir/a.txt"))
+	assert.Equal(t, "b", readString(t, memfs, "dir/b.txt"))
+	assert.Equal(t, "c", readString(t, memfs, "dir/c.txt"))
+	_, err = memfs.Stat("dir/new")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	info, err := memfs.Stat("dir/empty")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.NoError(t, memfs.Verify())
+
+	// the file count was restored, so the limit still allows exactly one more file
+	require.NoError(t, memfs.WriteFile("dir/d.txt", nil, 0o644))
+	assert.ErrorIs(t, memfs.WriteFile("dir/e.txt", nil, 0o644), ErrTooManyFiles)
+}
+
+func Test_TransactionErrors(t *testing.T) {
+	memfs := newTransactionFS(t)
+
+	err := memfs.Transaction(func(tx *Tx) error {
+		require.NoError(t, tx.WriteFile("dir/new.txt", nil, 0o644))
+		assert.ErrorIs(t, tx.Mkdir("dir/a.txt", 0o755), fs.ErrExist)
+		assert.ErrorIs(t, tx.Mkdir("missing/dir", 0o755), fs.ErrNotExist)
+		assert.ErrorIs(t, tx.Remove("dir/missing"), fs.ErrNotExist)
+		assert.ErrorIs(t, tx.Remove("dir"), fs.ErrInvalid)
+		assert.ErrorIs(t, tx.Rename("dir/a.txt", "dir/empty"), ErrIsDir)
+		return tx.WriteFile("missing/file.txt", nil, 0o644)
+	})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = memfs.Stat("dir/new.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Equal(t, "a", readString(t, memfs, "dir/a.txt"))
+	info, err := memfs.Stat("dir/empty")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_TransactionTrash(t *testing.T) {
+	memfs := newTransactionFS(t, WithTrash())
+	require.NoError(t, memfs.Transaction(func(tx *Tx) error {
+		return tx.Remove("dir/a.txt")
+	}))
+	require.NoError(t, memfs.Restore("dir/a.txt"))
+	assert.Equal(t, "a", readString(t, memfs, "dir/a.txt"))
+}
@@ -0,0 +1,231 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// trashEntry is a file or directory which has been detached from the tree, such as one removed while the
+// filesystem was created WithTrash
+type trashEntry struct {
+	file *file
+	dir  *dir
+}
+
+// WithTrash makes Remove and RemoveAll move entries into a hidden trash instead of discarding them, so that they
+// can be brought back with Restore. Trashed entries are not visible through any other method, and continue to
+// use memory until EmptyTrash is called.
+func WithTrash() Option {
+	return func(o *options) {
+		o.trash = true
+	}
+}
+
+// Restore reinstates the most recently removed entry at the named path from the trash, along with its contents if
+// it is a directory. The parent directory must exist, and nothing else may exist at the path, otherwise an error
+// wrapping fs.ErrNotExist or fs.ErrExist respectively is returned. An error wrapping fs.ErrNotExist is also
+// returned if the path is not in the trash.
+func (m *FS) Restore(name string) error {
+	path, err := m.realpath("restore", name, false)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return &fs.PathError{Op: "restore", Path: name, Err: fs.ErrInvalid}
+	}
+	key := m.dir.pathFromRoot(path)
+
+	t := m.dir.tree
+	t.trashMu.Lock()
+	defer t.trashMu.Unlock()
+	entries := t.trash[key]
+	if len(entries) == 0 {
+		return &fs.PathError{Op: "restore", Path: path, Err: fs.ErrNotExist}
+	}
+	if err := m.attach(path, entries[len(entries)-1]); err != nil {
+		return &fs.PathError{Op: "restore", Path: path, Err: err}
+	}
+
+	if len(entries) == 1 {
+		delete(t.trash, key)
+	} else {
+		t.trash[key] = entries[:len(entries)-1]
+	}
+	return nil
+}
+
+// EmptyTrash permanently discards every entry in the trash.
+func (m *FS) EmptyTrash() {
+	t := m.dir.tree
+	t.trashMu.Lock()
+	trash := t.trash
+	t.trash = nil
+	t.trashMu.Unlock()
+
+	for _, entries := range trash {
+		for _, entry := range entries {
+			entry.discard()
+		}
+	}
+}
+
+// discard releases storage held by the files within the entry, once it is no longer needed
+func (e trashEntry) discard() {
+	if e.file != nil {
+		e.file.discard()
+		return
+	}
+	_ = e.dir.walk("", func(_ string, _ *dir, f *file) error {
+		if f != nil {
+			f.discard()
+		}
+		return nil
+	})
+}
+
+// moveToTrash detaches the entry at the resolved path and adds it to the trash, as Remove or RemoveAll would
+// delete it
+func (m *FS) moveToTrash(path string, recursive bool) error {
+	if path == "" {
+		return nil
+	}
+	entry, err := m.detach(path, recursive)
+	if err != nil {
+		return err
+	}
+	m.addToTrash(path, entry)
+	return nil
+}
+
+// addToTrash adds an entry which has been detached from the resolved path to the trash
+func (m *FS) addToTrash(path string, entry trashEntry) {
+	t := m.dir.tree
+	key := m.dir.pathFromRoot(path)
+	t.trashMu.Lock()
+	if t.trash == nil {
+		t.trash = map[string][]trashEntry{}
+	}
+	t.trash[key] = append(t.trash[key], entry)
+	t.trashMu.Unlock()
+}
+
+// detach removes the file or directory at the resolved path from the tree without discarding it, so that it can
+// be attached again later. Non-empty directories are only detached if recursive is true.
+func (m *FS) detach(path string, recursive bool) (trashEntry, error) {
+	var entry trashEntry
+	parentPath, base := splitPath(path, m.dir.sep())
+	parent, err := m.dir.getDir(parentPath)
+	if err != nil {
+		return entry, err
+	}
+	parent.populate()
+
+	parent.Lock()
+	if f, ok := parent.files[base]; ok {
+		if f.isImmutable() {
+			parent.Unlock()
+			return entry, ErrImmutable
+		}
+		delete(parent.files, base)
+		entry.file = f
+	} else if sub, ok := parent.dirs[base]; ok {
+		sub.populate()
+		sub.RLock()
+		empty := len(sub.dirs) == 0 && len(sub.files) == 0
+		sub.RUnlock()
+		if !empty && !recursive {
+			parent.Unlock()
+			return entry, fs.ErrInvalid
+		}
+		if sub.containsImmutable() {
+			parent.Unlock()
+			return entry, ErrImmutable
+		}
+		delete(parent.dirs, base)
+		entry.dir = sub
+	} else {
+		parent.Unlock()
+		return entry, fs.ErrNotExist
+	}
+	parent.touch(time.Now())
+	parent.Unlock()
+	parent.invalidateSize()
+	m.dir.tree.countFiles(entry, -1)
+	return entry, nil
+}
+
+// attach inserts an entry which was previously detached at the resolved path. The parent directory must exist
+// and nothing else may exist at the path.
+func (m *FS) attach(path string, entry trashEntry) error {
+	parentPath, base := splitPath(path, m.dir.sep())
+	parent, err := m.dir.getDir(parentPath)
+	if err != nil {
+		return err
+	}
+	parent.populate()
+
+	parent.Lock()
+	defer parent.Unlock()
+	_, isFile := parent.files[base]
+	_, isDir := parent.dirs[base]
+	if isFile || isDir {
+		return fs.ErrExist
+	}
+	if entry.file != nil {
+		entry.file.Lock()
+		entry.file.info.name = base
+		entry.file.Unlock()
+		parent.files[base] = entry.file
+	} else {
+		entry.dir.Lock()
+		entry.dir.info.name = base
+		entry.dir.Unlock()
+		entry.dir.setParent(parent)
+		parent.dirs[base] = entry.dir
+	}
+	parent.touch(time.Now())
+	m.dir.tree.countFiles(entry, 1)
+	parent.invalidateSize()
+	return nil
+}
+
+// countFiles adjusts the number of regular files by delta for each regular file within a trash entry, and the
+// number of entries by delta for each entry within it
+func (t *tree) countFiles(entry trashEntry, delta int64) {
+	count := func(f *file) {
+		if !f.stat().Mode().IsRegular() {
+			return
+		}
+		if delta > 0 {
+			t.countFile()
+		} else {
+			t.releaseFile()
+		}
+	}
+	if entry.file != nil {
+		count(entry.file)
+		t.countEntries(delta)
+		return
+	}
+	_ = entry.dir.walk("", func(_ string, _ *dir, f *file) error {
+		if f != nil {
+			count(f)
+		}
+		t.countEntries(delta)
+		return nil
+	})
+}
+
+// pathFromRoot returns the path from the root of the filesystem of the path relative to d, so that views returned
+// by Sub share trash entries with the filesystem they came from
+func (d *dir) pathFromRoot(path string) string {
+	var parts []string
+	for p := d; p.getParent() != nil; p = p.getParent() {
+		parts = append([]string{p.info.name}, parts...)
+	}
+	if path != "" {
+		parts = append(parts, path)
+	}
+	return strings.Join(parts, d.sep())
+}
@@ -0,0 +1,103 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TrashRestoreFile(t *testing.T) {
+	memfs := New(WithTrash())
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/file.txt", []byte("first"), 0o644))
+	require.NoError(t, memfs.Remove("dir/file.txt"))
+	require.NoError(t, memfs.WriteFile("dir/file.txt", []byte("second"), 0o644))
+	require.NoError(t, memfs.Remove("dir/file.txt"))
+
+	_, err := memfs.Stat("dir/file.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	entries, err := memfs.ReadDir("dir")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoError(t, memfs.Verify())
+
+	require.NoError(t, memfs.Restore("dir/file.txt"))
+	data, err := memfs.ReadFile("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	assert.ErrorIs(t, memfs.Restore("dir/file.txt"), fs.ErrExist)
+
+	// the earlier deletion is still in the trash
+	require.NoError(t, memfs.Rename("dir/file.txt", "dir/second.txt"))
+	require.NoError(t, memfs.Restore("dir/file.txt"))
+	data, err = memfs.ReadFile("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	require.NoError(t, memfs.Remove("dir/file.txt"))
+	require.NoError(t, memfs.Restore("dir/file.txt"))
+	require.NoError(t, memfs.Rename("dir/file.txt", "dir/first.txt"))
+	assert.ErrorIs(t, memfs.Restore("dir/file.txt"), fs.ErrNotExist)
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_TrashRestoreDirectory(t *testing.T) {
+	memfs := New(WithTrash())
+	require.NoError(t, memfs.MkdirAll("a/b/c", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/c/file.txt", []byte("content"), 0o644))
+
+	assert.ErrorIs(t, memfs.Remove("a/b"), fs.ErrInvalid)
+	require.NoError(t, memfs.RemoveAll("a/b"))
+	_, err := memfs.Stat("a/b")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	size, err := memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	assert.NoError(t, memfs.Verify())
+
+	require.NoError(t, memfs.Restore("a/b"))
+	data, err := memfs.ReadFile("a/b/c/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	size, err = memfs.DirSize("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), size)
+	assert.NoError(t, memfs.Verify())
+}
+
+func Test_TrashRestoreErrors(t *testing.T) {
+	memfs := New(WithTrash())
+	require.NoError(t, memfs.MkdirAll("dir", 0o700))
+	require.NoError(t, memfs.WriteFile("dir/file.txt", nil, 0o644))
+
+	assert.ErrorIs(t, memfs.Restore("dir/file.txt"), fs.ErrNotExist)
+
+	require.NoError(t, memfs.RemoveAll("dir"))
+	assert.ErrorIs(t, memfs.Restore("dir/file.txt"), fs.ErrNotExist)
+	assert.ErrorIs(t, memfs.Remove("dir/missing"), fs.ErrNotExist)
+}
+
+func Test_EmptyTrash(t *testing.T) {
+	memfs := New(WithTrash())
+	require.NoError(t, memfs.WriteFile("file.txt", nil, 0o644))
+	require.NoError(t, memfs.Remove("file.txt"))
+
+	memfs.EmptyTrash()
+	assert.ErrorIs(t, memfs.Restore("file.txt"), fs.ErrNotExist)
+}
+
+func Test_TrashSub(t *testing.T) {
+	memfs := New(WithTrash())
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", nil, 0o644))
+
+	sub, err := memfs.Sub("a")
+	require.NoError(t, err)
+	require.NoError(t, sub.(*FS).Remove("b/file.txt"))
+	require.NoError(t, memfs.Restore("a/b/file.txt"))
+	_, err = memfs.Stat("a/b/file.txt")
+	assert.NoError(t, err)
+}
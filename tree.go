@@ -0,0 +1,101 @@
+package memoryfs
+
+import (
+	"io/fs"
+	"sync"
+	"sync/atomic"
+)
+
+// tree holds the configuration and accounting shared by every directory within a filesystem, including those
+// reached through Sub views
+type tree struct {
+	// 64-bit fields accessed atomically come first to keep them aligned on 32-bit platforms
+	files       int64 // number of regular files
+	entries     int64 // number of files, directories and symbolic links, excluding the root
+	handles     int64 // number of open file handles, see OpenHandles
+	readLatency int64 // time.Duration slept by each Read on a file handle, see SetReadLatency
+
+	opts *options
+
+	checkerMu sync.RWMutex
+	checker   AccessChecker
+
+	spills   spillRegistry
+	accesses accessLog
+	events   eventLog
+
+	flocks flockTable
+	lru    lruCache // content of mounted files, see WithLRU
+
+	trashMu sync.Mutex
+	trash   map[string][]trashEntry // keyed by path from the root, most recent last
+}
+
+// reserveFile accounts for a new regular file, returning false if doing so would exceed the configured maximum
+func (t *tree) reserveFile() bool {
+	n := atomic.AddInt64(&t.files, 1)
+	if t.opts.maxFiles > 0 && n > int64(t.opts.maxFiles) {
+		atomic.AddInt64(&t.files, -1)
+		return false
+	}
+	return true
+}
+
+// releaseFile accounts for the removal of a regular file
+func (t *tree) releaseFile() {
+	atomic.AddInt64(&t.files, -1)
+}
+
+// countFile accounts for a regular file which is not subject to the configured maximum, such as one loaded from
+// a mount
+func (t *tree) countFile() {
+	atomic.AddInt64(&t.files, 1)
+}
+
+// reserveEntries accounts for n new entries, returning false if doing so would exceed the configured maximum
+func (t *tree) reserveEntries(n int64) bool {
+	total := atomic.AddInt64(&t.entries, n)
+	if t.opts.maxEntries > 0 && total > int64(t.opts.maxEntries) {
+		atomic.AddInt64(&t.entries, -n)
+		return false
+	}
+	return true
+}
+
+// releaseEntries accounts for the removal of n entries
+func (t *tree) releaseEntries(n int64) {
+	atomic.AddInt64(&t.entries, -n)
+}
+
+// countEntries accounts for n entries which are not subject to the configured maximum, such as those loaded from
+// a mount
+func (t *tree) countEntries(n int64) {
+	atomic.AddInt64(&t.entries, n)
+}
+
+// openHandle accounts for a file handle returned to a caller, which calls closeHandle once it is closed. It returns
+// false if this would exceed the configured maximum number of open handles.
+func (t *tree) openHandle() bool {
+	n := atomic.AddInt64(&t.handles, 1)
+	if t.opts.maxOpenHandles > 0 && n > int64(t.opts.maxOpenHandles) {
+		atomic.AddInt64(&t.handles, -1)
+		return false
+	}
+	return true
+}
+
+func (t *tree) closeHandle() {
+	atomic.AddInt64(&t.handles, -1)
+}
+
+// replaceFile accounts for an entry with mode previous being replaced by one with mode next, returning false if
+// this would exceed the configured maximum number of files. New entries are replaced from fs.ModeIrregular.
+func (t *tree) replaceFile(previous fs.FileMode, next fs.FileMode) bool {
+	switch {
+	case !previous.IsRegular() && next.IsRegular():
+		return t.reserveFile()
+	case previous.IsRegular() && !next.IsRegular():
+		t.releaseFile()
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package memoryfs
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// IntegrityError describes the inconsistencies found by Verify
+type IntegrityError struct {
+	Problems []string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("filesystem integrity check failed with %d problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Verify checks the internal consistency of the filesystem, returning an *IntegrityError describing every problem
+// found, or nil if there are none. It checks that the size of each in-memory file matches its content, that no
+// directory contains a file and a directory of the same name, that names and parent links agree with the tree
+// structure, and that cached directory sizes and the counts used by WithMaxFiles and WithMaxEntries agree with the
+// tree.
+func (m *FS) Verify() error {
+	var problems []string
+	var files, entries int64
+	_ = m.dir.walk("", func(path string, d *dir, f *file) error {
+		if path != "" {
+			entries++
+		}
+		if f != nil {
+			f.RLock()
+			if f.info.mode.IsRegular() {
+				files++
+			}
+			if f.inMemory() && f.info.size != int64(len(f.content)) {
+				problems = append(problems, fmt.Sprintf("'%s' has size %d but %d bytes of content", path, f.info.size, len(f.content)))
+			}
+			f.RUnlock()
+			return nil
+		}
+
+		d.RLock()
+		defer d.RUnlock()
+		for name, sub := range d.dirs {
+			child := joinPath(path, name, m.dir.sep())
+			if _, ok := d.files[name]; ok {
+				problems = append(problems, fmt.Sprintf("'%s' exists as both a file and a directory", child))
+			}
+			if sub.getParent() != d {
+				problems = append(problems, fmt.Sprintf("directory '%s' does not link back to its parent", child))
+			}
+			if sub.info.name != name {
+				problems = append(problems, fmt.Sprintf("directory '%s' is named '%s'", child, sub.info.name))
+			}
+		}
+		for name, f := range d.files {
+			if info := f.stat(); info.Name() != name {
+				problems = append(problems, fmt.Sprintf("file '%s' is named '%s'", joinPath(path, name, m.dir.sep()), info.Name()))
+			}
+		}
+		return nil
+	})
+
+	_ = m.dir.walk("", func(path string, d *dir, _ *file) error {
+		if d == nil {
+			return nil
+		}
+		gen := atomic.LoadUint64(&d.sizeGen)
+		d.sizeMu.Lock()
+		cached, valid := d.cachedSize, d.sizeValid == gen+1
+		d.sizeMu.Unlock()
+		if !valid {
+			return nil
+		}
+		var actual int64
+		_ = d.walk("", func(_ string, _ *dir, f *file) error {
+			if f != nil {
+				actual += f.stat().Size()
+			}
+			return nil
+		})
+		if actual != cached {
+			problems = append(problems, fmt.Sprintf("directory '%s' has cached size %d but contains %d bytes", path, cached, actual))
+		}
+		return nil
+	})
+
+	// the file count covers the whole tree, so it can only be checked from the root
+	if m.dir.getParent() == nil {
+		if counted := atomic.LoadInt64(&m.dir.tree.files); counted != files {
+			problems = append(problems, fmt.Sprintf("file count is %d but the tree contains %d regular files", counted, files))
+		}
+		if counted := atomic.LoadInt64(&m.dir.tree.entries); counted != entries {
+			problems = append(problems, fmt.Sprintf("entry count is %d but the tree contains %d entries", counted, entries))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &IntegrityError{Problems: problems}
+	}
+	return nil
+}
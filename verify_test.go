@@ -0,0 +1,47 @@
+package memoryfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Verify(t *testing.T) {
+	memfs := New(WithMaxFiles(10))
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/other.txt", []byte("world"), 0o644))
+	require.NoError(t, memfs.Symlink("a/other.txt", "link"))
+	require.NoError(t, memfs.Rename("a/other.txt", "a/b/moved.txt"))
+	require.NoError(t, memfs.Remove("a/b/file.txt"))
+	_, err := memfs.DirSize(".")
+	require.NoError(t, err)
+
+	require.NoError(t, memfs.Verify())
+}
+
+func Test_VerifyReportsAllProblems(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/x", 0o700))
+	require.NoError(t, memfs.WriteFile("a/file.txt", []byte("hello"), 0o644))
+
+	a, err := memfs.dir.getDir("a")
+	require.NoError(t, err)
+	// corrupt the tree directly, as a mutation bug might
+	a.files["file.txt"].info.size = 99
+	a.files["x"] = &file{info: fileinfo{name: "x"}, content: []byte{}}
+	memfs.dir.tree.files = 7
+
+	err = memfs.Verify()
+	require.Error(t, err)
+
+	var integrityErr *IntegrityError
+	require.True(t, errors.As(err, &integrityErr))
+	assert.Len(t, integrityErr.Problems, 4)
+	assert.Contains(t, err.Error(), "has size 99 but 5 bytes of content")
+	assert.Contains(t, err.Error(), "exists as both a file and a directory")
+	assert.Contains(t, err.Error(), "file count is 7 but the tree contains 2 regular files")
+	assert.Contains(t, err.Error(), "entry count is 3 but the tree contains 4 entries")
+}
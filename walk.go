@@ -0,0 +1,76 @@
+package memoryfs
+
+import (
+	"io/fs"
+)
+
+// WalkFollow walks the file tree rooted at root, calling fn for each file or directory in the tree, including root.
+// It behaves like fs.WalkDir, except that symbolic links to directories are followed and their targets walked.
+// Entries are reported by their path through the link. If following a link would revisit one of its own ancestors,
+// fn is called for the link with an error wrapping ErrSymlinkLoop and the link is not descended into.
+func (m *FS) WalkFollow(root string, fn fs.WalkDirFunc) error {
+	info, err := m.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walkFollow(root, fs.FileInfoToDirEntry(info), fn, map[string]struct{}{})
+	}
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (m *FS) walkFollow(name string, d fs.DirEntry, fn fs.WalkDirFunc, ancestors map[string]struct{}) error {
+	isDir := d.IsDir()
+	if d.Type()&fs.ModeSymlink != 0 {
+		if info, err := m.Stat(name); err == nil {
+			isDir = info.IsDir()
+		}
+	}
+
+	if !isDir {
+		return fn(name, d, nil)
+	}
+
+	real, err := m.realpath("walk", name, true)
+	if err != nil {
+		return fn(name, d, err)
+	}
+	if _, ok := ancestors[real]; ok {
+		if err := fn(name, d, &fs.PathError{Op: "walk", Path: name, Err: ErrSymlinkLoop}); err != nil && err != fs.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	if err := fn(name, d, nil); err != nil {
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		if err := fn(name, d, err); err != nil {
+			if err == fs.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+
+	ancestors[real] = struct{}{}
+	defer delete(ancestors, real)
+	parent := m.Clean(name)
+	for _, entry := range entries {
+		if err := m.walkFollow(joinPath(parent, entry.Name(), m.dir.sep()), entry, fn, ancestors); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package memoryfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WalkFollow(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("farm", 0o700))
+	require.NoError(t, memfs.MkdirAll("store/pkg", 0o700))
+	require.NoError(t, memfs.WriteFile("store/pkg/lib.so", []byte("lib"), 0o644))
+	require.NoError(t, memfs.Symlink("../store/pkg", "farm/pkg"))
+
+	var paths []string
+	err := memfs.WalkFollow("farm", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"farm", "farm/pkg", "farm/pkg/lib.so"}, paths)
+}
+
+func Test_WalkFollowDetectsLoops(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/b", 0o700))
+	require.NoError(t, memfs.WriteFile("a/b/file.txt", []byte("hello"), 0o644))
+	require.NoError(t, memfs.Symlink("../..", "a/b/up"))
+
+	var paths []string
+	var loops []string
+	err := memfs.WalkFollow(".", func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, ErrSymlinkLoop) {
+			loops = append(loops, path)
+			return nil
+		}
+		require.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".", "a", "a/b", "a/b/file.txt"}, paths)
+	assert.Equal(t, []string{"a/b/up"}, loops)
+}
+
+func Test_WalkFollowSkipDir(t *testing.T) {
+	memfs := New()
+	require.NoError(t, memfs.MkdirAll("a/skip", 0o700))
+	require.NoError(t, memfs.WriteFile("a/skip/hidden.txt", []byte("hidden"), 0o644))
+	require.NoError(t, memfs.WriteFile("a/visible.txt", []byte("visible"), 0o644))
+
+	var paths []string
+	err := memfs.WalkFollow("a", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if path == "a/skip" {
+			return fs.SkipDir
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/visible.txt"}, paths)
+}
+
+func Test_WalkFollowSeparator(t *testing.T) {
+	memfs := New(WithSeparator(`\`))
+	require.NoError(t, memfs.MkdirAll(`farm`, 0o700))
+	require.NoError(t, memfs.MkdirAll(`store\pkg`, 0o700))
+	require.NoError(t, memfs.WriteFile(`store\pkg\lib.so`, []byte("lib"), 0o644))
+	require.NoError(t, memfs.Symlink(`..\store\pkg`, `farm\pkg`))
+
+	var paths []string
+	err := memfs.WalkFollow(".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".",
+		`farm`,
+		`farm\pkg`,
+		`farm\pkg\lib.so`,
+		`store`,
+		`store\pkg`,
+		`store\pkg\lib.so`,
+	}, paths)
+
+	for _, path := range paths {
+		_, err := memfs.Stat(path)
+		assert.NoError(t, err, path)
+	}
+}
@@ -0,0 +1,62 @@
+package memoryfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ReadZip reads a zip archive from r, which is size bytes long, and writes its directories and regular files into the filesystem.
+// Entry paths are validated so that none can escape the root (see WithSecureImport), absolute paths are re-rooted.
+// Other entry types are ignored. All entries are validated before anything is written, so an aborted import leaves the filesystem unchanged.
+func (m *FS) ReadZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip: %w", err)
+	}
+
+	paths := make([]string, len(zr.File))
+	for i, f := range zr.File {
+		path, ok, err := m.importPath("readzip", f.Name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			paths[i] = path
+		}
+	}
+
+	for i, f := range zr.File {
+		if paths[i] == "" {
+			continue
+		}
+		info := f.FileInfo()
+		if info.IsDir() {
+			if err := m.importDir(paths[i], info.Mode().Perm(), f.Modified); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read zip entry '%s': %w", f.Name, err)
+		}
+		if err := m.importFile(paths[i], data, info.Mode().Perm(), f.Modified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	return ioutil.ReadAll(rc)
+}
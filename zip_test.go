@@ -0,0 +1,70 @@
+package memoryfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	buffer := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buffer.Bytes()
+}
+
+func Test_ReadZip(t *testing.T) {
+	archive := buildZip(t, map[string]string{
+		"a/b/c.txt": "hello",
+		"/abs.txt":  "rerooted",
+		"dir/":      "",
+	})
+
+	memfs := New()
+	require.NoError(t, memfs.ReadZip(bytes.NewReader(archive), int64(len(archive))))
+
+	data, err := memfs.ReadFile("a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	data, err = memfs.ReadFile("abs.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "rerooted", string(data))
+
+	info, err := memfs.Stat("dir")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func Test_ReadZipRejectsTraversal(t *testing.T) {
+	archive := buildZip(t, map[string]string{
+		"good.txt":              "good",
+		"../../etc/cron.d/evil": "evil",
+	})
+
+	memfs := New()
+	err := memfs.ReadZip(bytes.NewReader(archive), int64(len(archive)))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnsafePath)
+
+	entries, err := memfs.ReadDir(".")
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	memfs = New(WithSecureImport(true))
+	require.NoError(t, memfs.ReadZip(bytes.NewReader(archive), int64(len(archive))))
+
+	entries, err = memfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "good.txt", entries[0].Name())
+}